@@ -0,0 +1,284 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/egovorukhin/egoudp/pool"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+)
+
+//Настройка HTTP API. Если Username пустой, то авторизация не требуется
+type Config struct {
+	Username string
+	Password string
+	Realm    string
+}
+
+//HTTP API для управления запущенным сервером:
+//
+//	GET    /health                 - состояние сервера
+//	GET    /connections            - список подключений
+//	GET    /connections/{hostname} - подключение
+//	DELETE /connections/{hostname} - отключить клиента
+//	GET    /routes                 - список маршрутов
+//	POST   /send                   - отправить ответ клиенту по hostname или login
+//
+//Для подключения по префиксу используйте http.StripPrefix
+type Handler struct {
+	srv server.IServer
+	Config
+}
+
+func New(srv server.IServer, config Config) *Handler {
+	if config.Realm == "" {
+		config.Realm = "egoudp"
+	}
+	return &Handler{
+		srv:    srv,
+		Config: config,
+	}
+}
+
+type Health struct {
+	Started     bool         `json:"started"`
+	Connections int          `json:"connections"`
+	Routes      int          `json:"routes"`
+	Pool        pool.Metrics `json:"pool"`
+}
+
+type Connection struct {
+	Hostname       string     `json:"hostname"`
+	IpAddress      string     `json:"ip_address"`
+	Family         string     `json:"family"`
+	Domain         string     `json:"domain"`
+	Login          string     `json:"login"`
+	Version        string     `json:"version"`
+	Connected      bool       `json:"connected"`
+	Pending        bool       `json:"pending"`
+	ConnectTime    time.Time  `json:"connect_time"`
+	DisconnectTime *time.Time `json:"disconnect_time,omitempty"`
+	Received       uint64     `json:"received"`
+	LastSeen       *time.Time `json:"last_seen,omitempty"`
+}
+
+type Route struct {
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	Limit  *Limit `json:"limit,omitempty"`
+}
+
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+//Ответ клиенту. Указывается Hostname или Login
+type Send struct {
+	Hostname    string `json:"hostname"`
+	Login       string `json:"login"`
+	StatusCode  int    `json:"status_code"`
+	Event       int    `json:"event"`
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+}
+
+type SendResult struct {
+	Sent int `json:"sent"`
+}
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", h.Realm))
+		writeError(w, http.StatusUnauthorized, errors.New("Требуется авторизация"))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "health":
+		h.only(w, r, http.MethodGet, h.health)
+	case path == "connections":
+		h.only(w, r, http.MethodGet, h.connections)
+	case strings.HasPrefix(path, "connections/"):
+		hostname := strings.TrimPrefix(path, "connections/")
+		switch r.Method {
+		case http.MethodGet:
+			h.connection(w, hostname)
+		case http.MethodDelete:
+			h.disconnect(w, hostname)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case path == "routes":
+		h.only(w, r, http.MethodGet, h.routes)
+	case path == "send":
+		h.only(w, r, http.MethodPost, h.send)
+	default:
+		writeError(w, http.StatusNotFound, errors.New(fmt.Sprintf("Путь [%s] не найден", r.URL.Path)))
+	}
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.Username == "" {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	//Сравниваем за постоянное время, чтобы не подсказывать пароль
+	u := subtle.ConstantTimeCompare([]byte(username), []byte(h.Username))
+	p := subtle.ConstantTimeCompare([]byte(password), []byte(h.Password))
+	return u&p == 1
+}
+
+func (h *Handler) only(w http.ResponseWriter, r *http.Request, method string, handler http.HandlerFunc) {
+	if r.Method != method {
+		methodNotAllowed(w, method)
+		return
+	}
+	handler(w, r)
+}
+
+func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
+	health := Health{
+		Started:     h.srv.IsStarted(),
+		Connections: len(h.srv.GetConnections()),
+		Routes:      len(h.srv.GetRoutes()),
+		Pool:        h.srv.PoolMetrics(),
+	}
+	status := http.StatusOK
+	if !health.Started {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
+func (h *Handler) connections(w http.ResponseWriter, r *http.Request) {
+	connections := h.srv.GetConnections()
+	list := make([]Connection, 0, len(connections))
+	for _, c := range connections {
+		list = append(list, toConnection(c))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Hostname < list[j].Hostname
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) connection(w http.ResponseWriter, hostname string) {
+	c, ok := h.srv.GetConnections()[strings.ToUpper(hostname)]
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New(fmt.Sprintf("host: %s - подключение отсутствует!", hostname)))
+		return
+	}
+	writeJSON(w, http.StatusOK, toConnection(c))
+}
+
+func (h *Handler) disconnect(w http.ResponseWriter, hostname string) {
+	err := h.srv.Disconnect(hostname)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) routes(w http.ResponseWriter, r *http.Request) {
+	routes := h.srv.GetRoutes()
+	list := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		r := Route{
+			Path:   route.Path,
+			Method: route.Method.String(),
+		}
+		if !route.Limit.IsNil() {
+			r.Limit = &Limit{Rate: route.Limit.Rate, Burst: route.Limit.Burst}
+		}
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Method < list[j].Method
+	})
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (h *Handler) send(w http.ResponseWriter, r *http.Request) {
+	var req Send
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if (req.Hostname == "") == (req.Login == "") {
+		writeError(w, http.StatusBadRequest, errors.New("Нужно указать hostname или login"))
+		return
+	}
+	resp := &protocol.Response{
+		StatusCode:  protocol.StatusCode(req.StatusCode),
+		Event:       req.Event,
+		ContentType: req.ContentType,
+		Data:        protocol.ToRunes(req.Data),
+	}
+	if req.Login != "" {
+		writeJSON(w, http.StatusOK, SendResult{Sent: h.srv.SendByLogin(req.Login, resp)})
+		return
+	}
+	_, err = h.srv.Send(req.Hostname, resp)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SendResult{Sent: 1})
+}
+
+func toConnection(c *server.Connection) Connection {
+	stats := c.Stats()
+	conn := Connection{
+		Hostname:       c.Hostname,
+		IpAddress:      c.IpAddress.String(),
+		Family:         c.Family,
+		Domain:         c.Domain,
+		Login:          c.Login,
+		Version:        c.Version,
+		Connected:      c.Connected.Get(),
+		Pending:        c.Pending.Get(),
+		ConnectTime:    c.ConnectTime,
+		DisconnectTime: c.DisconnectTime,
+		Received:       stats.Received,
+	}
+	if !stats.LastSeen.IsZero() {
+		conn.LastSeen = &stats.LastSeen
+	}
+	return conn
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("Метод не поддерживается"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
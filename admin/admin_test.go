@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+)
+
+func TestHandler(t *testing.T) {
+	srv := server.New(server.Config{BufferSize: 1024, DisconnectTimeout: 30})
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("winter", protocol.MethodGet, func(c *server.Connection, resp protocol.IResponse, req protocol.Request) {})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	//Подключаем клиента
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: srv.(*server.Server).LocalAddr().(*net.UDPAddr).Port}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write(protocol.New("pc-1", "user", "hq", "1.0.0").Marshal())
+	time.Sleep(20 * time.Millisecond)
+
+	ts := httptest.NewServer(New(srv, Config{Username: "admin", Password: "secret"}))
+	defer ts.Close()
+
+	do := func(method, path, body string, v interface{}) int {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			_ = json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+
+	resp, err := http.Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("без авторизации: %d", resp.StatusCode)
+	}
+
+	var health Health
+	if code := do(http.MethodGet, "/health", "", &health); code != http.StatusOK || !health.Started || health.Connections != 1 {
+		t.Errorf("health: %d %+v", code, health)
+	}
+	var connections []Connection
+	do(http.MethodGet, "/connections", "", &connections)
+	if len(connections) != 1 || connections[0].Hostname != "PC-1" || connections[0].Received != 1 {
+		t.Errorf("connections: %+v", connections)
+	}
+	var routes []Route
+	do(http.MethodGet, "/routes", "", &routes)
+	if len(routes) != 1 || routes[0].Path != "winter" {
+		t.Errorf("routes: %+v", routes)
+	}
+	var sent SendResult
+	if code := do(http.MethodPost, "/send", `{"login":"USER","data":"Как жизнь?"}`, &sent); code != http.StatusOK || sent.Sent != 1 {
+		t.Errorf("send: %d %+v", code, sent)
+	}
+	if code := do(http.MethodPost, "/send", `{}`, nil); code != http.StatusBadRequest {
+		t.Errorf("send без адресата: %d", code)
+	}
+	if code := do(http.MethodDelete, "/connections/pc-1", "", nil); code != http.StatusNoContent {
+		t.Errorf("disconnect: %d", code)
+	}
+	if code := do(http.MethodGet, "/connections/pc-1", "", nil); code != http.StatusNotFound {
+		t.Errorf("после отключения: %d", code)
+	}
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//Тип события подключения
+type Kind string
+
+const (
+	KindConnected    Kind = "connect"
+	KindReconnected  Kind = "reconnect"
+	KindDisconnected Kind = "disconnect"
+	KindAuthFailure  Kind = "auth_failure"
+)
+
+//Изменение данных подключения при переподключении
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+//Событие журнала подключений
+type Event struct {
+	Time        time.Time `json:"time"`
+	Kind        Kind      `json:"kind"`
+	Hostname    string    `json:"hostname"`
+	IpAddress   string    `json:"ip_address"`
+	Domain      string    `json:"domain"`
+	Login       string    `json:"login"`
+	Version     string    `json:"version"`
+	ConnectTime time.Time `json:"connect_time"`
+	Changes     []Change  `json:"changes,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+//Приемник событий журнала
+type Sink interface {
+	Write(e Event) error
+	Close() error
+}
+
+//Приемник, по которому можно искать события
+type Querier interface {
+	Query(f Filter) ([]Event, error)
+}
+
+var ErrNotQueryable = errors.New("Журнал не поддерживает поиск")
+
+//Условия поиска событий, пустые поля не учитываются.
+//From включительно, To не включительно
+type Filter struct {
+	Hostname string
+	Login    string
+	Kind     Kind
+	From     time.Time
+	To       time.Time
+}
+
+func (f Filter) Match(e Event) bool {
+	if f.Hostname != "" && !strings.EqualFold(f.Hostname, e.Hostname) {
+		return false
+	}
+	if f.Login != "" && !strings.EqualFold(f.Login, e.Login) {
+		return false
+	}
+	if f.Kind != "" && f.Kind != e.Kind {
+		return false
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+//Ищем события, если приемник поддерживает поиск
+func Query(sink Sink, f Filter) ([]Event, error) {
+	q, ok := sink.(Querier)
+	if !ok {
+		return nil, ErrNotQueryable
+	}
+	return q.Query(f)
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func events(n int) []Event {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	list := make([]Event, n)
+	for i := range list {
+		list[i] = Event{
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Kind:     KindConnected,
+			Hostname: fmt.Sprintf("PC-%d", i%2),
+			Login:    "user",
+		}
+	}
+	return list
+}
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3)
+	list := events(5)
+	for _, e := range list {
+		_ = ring.Write(e)
+	}
+	got, _ := ring.Query(Filter{})
+	if len(got) != 3 || !got[0].Time.Equal(list[2].Time) || !got[2].Time.Equal(list[4].Time) {
+		t.Errorf("Query: %v", got)
+	}
+	got, _ = ring.Query(Filter{Hostname: "pc-0"})
+	if len(got) != 2 {
+		t.Errorf("Hostname: %v", got)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	list := events(4)
+	list[3].Kind = KindReconnected
+	list[3].Changes = []Change{{Field: "login", Old: "user", New: "admin"}}
+	for _, e := range list {
+		if err := file.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Query(file, Filter{From: list[1].Time, To: list[3].Time})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Time.Equal(list[1].Time) {
+		t.Errorf("From/To: %v", got)
+	}
+	got, _ = Query(file, Filter{Login: "USER", Kind: KindReconnected})
+	if len(got) != 1 || len(got[0].Changes) != 1 || got[0].Changes[0].New != "admin" {
+		t.Errorf("Kind: %v", got)
+	}
+}
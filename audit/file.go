@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+//Журнал в файле, одно событие в json на строку.
+//Файл дописывается, поиск читает файл целиком
+type File struct {
+	sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		path: path,
+		file: file,
+	}, nil
+}
+
+func (f *File) Write(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f.Lock()
+	defer f.Unlock()
+	_, err = f.file.Write(append(b, '\n'))
+	return err
+}
+
+func (f *File) Query(filter Filter) (events []Event, err error) {
+	f.Lock()
+	defer f.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return events, errors.New(fmt.Sprintf("%s:%d: %v", f.path, line, err))
+		}
+		if filter.Match(e) {
+			events = append(events, e)
+		}
+	}
+	return events, scanner.Err()
+}
+
+func (f *File) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,56 @@
+package audit
+
+import "sync"
+
+const DefaultRingSize = 1024
+
+//Журнал в памяти, хранит последние size событий
+type Ring struct {
+	sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	return &Ring{
+		events: make([]Event, size),
+	}
+}
+
+func (r *Ring) Write(e Event) error {
+	r.Lock()
+	defer r.Unlock()
+	r.events[r.next] = e
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+//События в порядке записи, от старых к новым
+func (r *Ring) Query(f Filter) (events []Event, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.full {
+		events = r.filter(events, r.events[r.next:], f)
+	}
+	return r.filter(events, r.events[:r.next], f), nil
+}
+
+func (r *Ring) filter(dst, src []Event, f Filter) []Event {
+	for _, e := range src {
+		if f.Match(e) {
+			dst = append(dst, e)
+		}
+	}
+	return dst
+}
+
+func (r *Ring) Close() error {
+	return nil
+}
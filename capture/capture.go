@@ -0,0 +1,245 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+//Направление пакета относительно того, кто записывает
+type Direction byte
+
+const (
+	DirectionIn Direction = iota + 1
+	DirectionOut
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionIn:
+		return "in"
+	case DirectionOut:
+		return "out"
+	}
+	return fmt.Sprintf("Direction(%d)", d)
+}
+
+//Захваченный пакет. Addr - адрес другой стороны
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Addr      string
+	Data      []byte
+}
+
+//Приемник захваченных пакетов. Data действителен только на время
+//вызова Capture, буфер пакета переиспользуется
+type Sink interface {
+	Capture(r Record)
+}
+
+//Формат файла: magic, затем записи
+//	8 байт - время в наносекундах unix
+//	1 байт - направление
+//	2 байта - длина адреса, адрес
+//	4 байта - длина пакета, пакет
+//Числа в big-endian
+var magic = []byte("EGOUDPCAP1")
+
+var ErrFormat = errors.New("Неверный формат файла захвата")
+
+//Запись пакетов в файл захвата
+type Writer struct {
+	sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	err    error
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{
+		w: bufio.NewWriter(w),
+	}
+	if c, ok := w.(io.Closer); ok {
+		writer.closer = c
+	}
+	_, err := writer.w.Write(magic)
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) Capture(r Record) {
+	_ = w.Write(r)
+}
+
+//Пишем запись, после первой ошибки запись прекращается
+func (w *Writer) Write(r Record) error {
+	w.Lock()
+	defer w.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	var header [15]byte
+	binary.BigEndian.PutUint64(header[0:8], uint64(r.Time.UnixNano()))
+	header[8] = byte(r.Direction)
+	binary.BigEndian.PutUint16(header[9:11], uint16(len(r.Addr)))
+	_, w.err = w.w.Write(header[:11])
+	if w.err == nil {
+		_, w.err = w.w.WriteString(r.Addr)
+	}
+	if w.err == nil {
+		binary.BigEndian.PutUint32(header[11:15], uint32(len(r.Data)))
+		_, w.err = w.w.Write(header[11:15])
+	}
+	if w.err == nil {
+		_, w.err = w.w.Write(r.Data)
+	}
+	return w.err
+}
+
+func (w *Writer) Flush() error {
+	w.Lock()
+	defer w.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+func (w *Writer) Close() error {
+	err := w.Flush()
+	if w.closer != nil {
+		if e := w.closer.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Чтение файла захвата
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{
+		r: bufio.NewReader(r),
+	}
+	if c, ok := r.(io.Closer); ok {
+		reader.closer = c
+	}
+	b := make([]byte, len(magic))
+	_, err := io.ReadFull(reader.r, b)
+	if err != nil || string(b) != string(magic) {
+		return nil, ErrFormat
+	}
+	return reader, nil
+}
+
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+//Следующая запись, io.EOF - записи закончились
+func (r *Reader) Next() (rec Record, err error) {
+	var header [11]byte
+	_, err = io.ReadFull(r.r, header[:])
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = ErrFormat
+		}
+		return
+	}
+	rec.Time = time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8])))
+	rec.Direction = Direction(header[8])
+	addr := make([]byte, binary.BigEndian.Uint16(header[9:11]))
+	_, err = io.ReadFull(r.r, addr)
+	if err != nil {
+		return rec, ErrFormat
+	}
+	rec.Addr = string(addr)
+	_, err = io.ReadFull(r.r, header[:4])
+	if err != nil {
+		return rec, ErrFormat
+	}
+	rec.Data = make([]byte, binary.BigEndian.Uint32(header[:4]))
+	_, err = io.ReadFull(r.r, rec.Data)
+	if err != nil {
+		return rec, ErrFormat
+	}
+	return rec, nil
+}
+
+//Читаем все записи
+func (r *Reader) ReadAll() (records []Record, err error) {
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+//Захват в памяти, для тестов
+type Memory struct {
+	sync.Mutex
+	records []Record
+}
+
+func NewMemory() *Memory {
+	return new(Memory)
+}
+
+func (m *Memory) Capture(r Record) {
+	r.Data = append([]byte(nil), r.Data...)
+	m.Lock()
+	m.records = append(m.records, r)
+	m.Unlock()
+}
+
+func (m *Memory) Records() []Record {
+	m.Lock()
+	defer m.Unlock()
+	return append([]Record(nil), m.records...)
+}
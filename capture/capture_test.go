@@ -0,0 +1,57 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriterReader(t *testing.T) {
+	records := []Record{
+		{Time: time.Unix(1, 5), Direction: DirectionIn, Addr: "127.0.0.1:5000", Data: []byte("^4:PC-1$")},
+		{Time: time.Unix(2, 0), Direction: DirectionOut, Addr: "[::1]:5000", Data: []byte("^1:1$")},
+		{Time: time.Unix(3, 0), Direction: DirectionIn, Addr: "", Data: nil},
+	}
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		w.Capture(r)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("records: %d", len(got))
+	}
+	for i := range records {
+		if !got[i].Time.Equal(records[i].Time) || got[i].Direction != records[i].Direction ||
+			got[i].Addr != records[i].Addr || !bytes.Equal(got[i].Data, records[i].Data) {
+			t.Errorf("%d: %+v != %+v", i, got[i], records[i])
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next: %v", err)
+	}
+
+	//Обрезанный файл
+	r, _ = NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	if _, err := r.ReadAll(); err != ErrFormat {
+		t.Errorf("обрезанный файл: %v", err)
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("pcap"))); err != ErrFormat {
+		t.Errorf("magic: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"net"
+	"time"
+)
+
+//Настройка воспроизведения
+type ReplayConfig struct {
+	//Какие пакеты отправлять, по умолчанию DirectionIn - входящие
+	//пакеты захваченные на сервере. Для захвата на клиенте - DirectionOut
+	Direction Direction
+	//Скорость воспроизведения относительно захвата,
+	//1 - как при захвате, 0 - без пауз
+	Speed float64
+	//Время ожидания ответов после отправки последнего пакета
+	Wait time.Duration
+}
+
+//Результат воспроизведения
+type ReplayResult struct {
+	Sent     int
+	Received int
+	//Ответы сервера, Addr - исходный адрес клиента из захвата
+	Responses []Record
+}
+
+//Воспроизводим захват на сервер addr. Для каждого адреса клиента
+//из захвата открывается свой сокет, поэтому сервер видит столько же
+//клиентов, сколько было при захвате
+func Replay(records []Record, addr *net.UDPAddr, config ReplayConfig) (*ReplayResult, error) {
+	if config.Direction == 0 {
+		config.Direction = DirectionIn
+	}
+	result := new(ReplayResult)
+	responses := make(chan Record, 1024)
+	done := make(chan struct{})
+	conns := map[string]*net.UDPConn{}
+	defer func() {
+		close(done)
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	var prev time.Time
+	for _, rec := range records {
+		if rec.Direction != config.Direction {
+			continue
+		}
+		conn, ok := conns[rec.Addr]
+		if !ok {
+			var err error
+			conn, err = net.DialUDP("udp", nil, addr)
+			if err != nil {
+				return result, err
+			}
+			conns[rec.Addr] = conn
+			go receive(conn, rec.Addr, responses, done)
+		}
+		if config.Speed > 0 && !prev.IsZero() {
+			time.Sleep(time.Duration(float64(rec.Time.Sub(prev)) / config.Speed))
+		}
+		prev = rec.Time
+		_, err := conn.Write(rec.Data)
+		if err != nil {
+			return result, err
+		}
+		result.Sent++
+	}
+
+	timeout := time.After(config.Wait)
+	for {
+		select {
+		case rec := <-responses:
+			result.Received++
+			result.Responses = append(result.Responses, rec)
+		case <-timeout:
+			return result, nil
+		}
+	}
+}
+
+func receive(conn *net.UDPConn, addr string, responses chan<- Record, done <-chan struct{}) {
+	b := make([]byte, 65535)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			return
+		}
+		rec := Record{
+			Time:      time.Now(),
+			Direction: DirectionIn,
+			Addr:      addr,
+			Data:      append([]byte(nil), b[:n]...),
+		}
+		select {
+		case responses <- rec:
+		case <-done:
+			return
+		}
+	}
+}
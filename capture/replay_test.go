@@ -0,0 +1,82 @@
+package capture_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+)
+
+func start(t *testing.T, sink capture.Sink) *server.Server {
+	srv := server.New(server.Config{BufferSize: 1024, DisconnectTimeout: 30, Capture: sink}).(*server.Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("echo", protocol.MethodGet, func(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func loopback(srv *server.Server) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: srv.LocalAddr().(*net.UDPAddr).Port}
+}
+
+//Захватываем трафик двух клиентов на сервере
+//и воспроизводим его на другом сервере
+func TestReplay(t *testing.T) {
+	sink := capture.NewMemory()
+	srv := start(t, sink)
+	for i := 0; i < 2; i++ {
+		conn, err := net.DialUDP("udp", nil, loopback(srv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := protocol.New(fmt.Sprintf("pc-%d", i), "user", "hq", "1.0.0")
+		_, _ = conn.Write(packet.Marshal())
+		packet.Request = protocol.NewRequest("echo", protocol.MethodGet)
+		packet.Request.Id = "1"
+		packet.Request.Data = protocol.ToRunes("Привет")
+		_, _ = conn.Write(packet.Marshal())
+		conn.Close()
+	}
+	//Ждем разбора пакетов
+	time.Sleep(50 * time.Millisecond)
+	_ = srv.Stop()
+
+	records := sink.Records()
+	var in, out int
+	for _, r := range records {
+		if r.Direction == capture.DirectionIn {
+			in++
+		} else {
+			out++
+		}
+	}
+	//На каждого клиента: подключение и ответ на запрос, затем отключение при остановке
+	if in != 4 || out != 6 {
+		t.Fatalf("in: %d, out: %d", in, out)
+	}
+
+	replayed := start(t, nil)
+	defer func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = replayed.Stop()
+	}()
+	result, err := capture.Replay(records, loopback(replayed), capture.ReplayConfig{Wait: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 4 || result.Received != 4 {
+		t.Errorf("sent: %d, received: %d", result.Sent, result.Received)
+	}
+	if n := len(replayed.GetConnections()); n != 2 {
+		t.Errorf("connections: %d", n)
+	}
+}
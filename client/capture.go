@@ -0,0 +1,21 @@
+package client
+
+import (
+	"net"
+	"time"
+
+	"github.com/egovorukhin/egoudp/capture"
+)
+
+//Передаем пакет в захват, адрес - адрес сервера
+func (c *Client) capture(conn net.Conn, direction capture.Direction, data []byte) {
+	if c.Capture == nil {
+		return
+	}
+	c.Capture.Capture(capture.Record{
+		Time:      time.Now(),
+		Direction: direction,
+		Addr:      conn.RemoteAddr().String(),
+		Data:      data,
+	})
+}
@@ -1,10 +1,15 @@
 package client
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"github.com/egovorukhin/egotimer"
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/discovery"
+	"github.com/egovorukhin/egoudp/pool"
 	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/trace"
+	"github.com/egovorukhin/egoudp/transport"
 	"github.com/google/uuid"
 	"io"
 	"log"
@@ -15,21 +20,73 @@ import (
 	"time"
 )
 
+//Запрос в очереди на отправку. Поля читаются и меняются под блокировкой:
+//запрос отправляет цикл send, а ответ записывает обработчик из пула
 type QItem struct {
+	sync.Mutex
 	Request  *protocol.Request
 	Response *protocol.Response
 	Sent     bool
 	Received bool
+	//Закрывается при получении ответа
+	received chan struct{}
+}
+
+func newQItem(req *protocol.Request) *QItem {
+	return &QItem{
+		Request:  req,
+		received: make(chan struct{}),
+	}
+}
+
+//Берем запрос для отправки, nil - запрос уже отправлен
+func (q *QItem) send() *protocol.Request {
+	q.Lock()
+	defer q.Unlock()
+	if q.Sent {
+		return nil
+	}
+	q.Sent = true
+	return q.Request
+}
+
+//Сохраняем ответ и будим ожидающий его Send, повторный ответ не учитываем
+func (q *QItem) receive(resp *protocol.Response) {
+	q.Lock()
+	defer q.Unlock()
+	if q.Received {
+		return
+	}
+	q.Response = resp
+	q.Received = true
+	close(q.received)
+}
+
+func (q *QItem) response() *protocol.Response {
+	q.Lock()
+	defer q.Unlock()
+	return q.Response
 }
 
 type Client struct {
 	Config
-	connection *net.UDPConn
+	connection connection
+	dialer     transport.Transport
+	endpoints  *endpoints
 	packet     *protocol.Packet
 	queue      sync.Map
+	pool       *pool.Pool
+	buffers    *pool.Buffers
 	timer      *egotimer.Timer
-	Connected  Connected
-	Started    Started
+	//Закрывается в Stop, будит цикл отправки
+	stopping chan struct{}
+	stopOnce sync.Once
+	//Закрывается, когда цикл отправки отправил событие отключения и завершился
+	sent      chan struct{}
+	Connected Connected
+	Started   Started
+	//Сервер найденный при запуске без Host
+	Discovered *discovery.Announcement
 	*log.Logger
 	Handler *Handler
 }
@@ -51,6 +108,24 @@ func (c *Started) Get() bool {
 	return c.value
 }
 
+//Сокет текущего сервера, меняется при переключении между серверами
+type connection struct {
+	sync.Mutex
+	value net.Conn
+}
+
+func (c *connection) Set(conn net.Conn) {
+	c.Lock()
+	c.value = conn
+	c.Unlock()
+}
+
+func (c *connection) Get() net.Conn {
+	c.Lock()
+	defer c.Unlock()
+	return c.value
+}
+
 type Connected struct {
 	sync.Mutex
 	value bool
@@ -69,11 +144,37 @@ func (c *Connected) Get() bool {
 }
 
 type Config struct {
+	//Сеть: udp (по умолчанию), udp4, udp6 или unixgram. Host - имя или
+	//IPv4/IPv6 адрес сервера, для unixgram - путь к файлу сокета сервера,
+	//Port в этом случае не используется
+	Network    string
 	Host       string
 	Port       int
 	BufferSize int
 	Timeout    int
 	LogLevel   LogLevel
+	//Пул обработчиков входящих пакетов
+	Pool pool.Config
+	//Сжатие запросов, сервер отвечает сжатыми данными тем же алгоритмом
+	Compression protocol.Compression
+	//Метаданные по умолчанию для всех запросов,
+	//значения из запроса имеют приоритет
+	Metadata map[string]string
+	//Трассировка запросов, nil - отключена
+	Tracer *trace.Tracer
+	//Захват входящих и исходящих пакетов, nil - отключен
+	Capture capture.Sink
+	//Транспорт пакетов, nil - UDP
+	Transport transport.Transport
+	//Поиск сервера при пустом Host: клиент подключается
+	//к первому ответившему серверу, nil - поиск отключен
+	Discovery *discovery.Config
+	//Список серверов вместо Host и Port. Клиент подключается к серверу
+	//с наивысшим приоритетом и переключается на следующий, когда текущий
+	//перестает отвечать
+	Endpoints []Endpoint
+	//Проверка серверов и переключение между ними, используется с Endpoints
+	Failover Failover
 }
 
 type LogLevel int
@@ -88,11 +189,17 @@ type IClient interface {
 	Stop()
 	SetLogger(out io.Writer, prefix string, flag int)
 	Send(req *protocol.Request) (*protocol.Response, error)
+	SendContext(ctx context.Context, req *protocol.Request) (*protocol.Response, error)
+	Call(path string, method protocol.Methods, contentType string, in, out interface{}) error
+	PoolMetrics() pool.Metrics
 	OnStart(handler HandleClient)
 	OnStop(handler HandleClient)
 	OnConnected(handler HandleClient)
 	OnDisconnected(handler HandleClient)
 	OnCheckConnection(handler HandleClient)
+	OnPush(handler HandlePush)
+	OnAttached(handler HandleEndpoint)
+	Health() []EndpointStatus
 }
 
 const udp = "udp"
@@ -111,75 +218,145 @@ func (c *Client) SetLogger(out io.Writer, prefix string, flag int) {
 
 func (c *Client) Start(hostname, login, domain, version string) error {
 
-	remoteAdder, err := net.ResolveUDPAddr(udp, fmt.Sprintf("%s:%d", c.Host, c.Port))
-	if err != nil {
-		return err
+	t := c.Transport
+	if t == nil {
+		var err error
+		t, err = transport.New(c.Network)
+		if err != nil {
+			return err
+		}
+	}
+	c.dialer = t
+	address := c.address(c.Host, c.Port)
+	if len(c.Endpoints) > 0 {
+		c.endpoints = newEndpoints(c.Endpoints, c.address)
+		address = c.endpoints.get(0).Address
+	} else if c.Host == "" && c.Discovery != nil {
+		a, err := discovery.Find(*c.Discovery)
+		if err != nil {
+			return err
+		}
+		c.Discovered = a
+		address = a.Address()
 	}
 
-	c.connection, err = net.DialUDP(udp, nil, remoteAdder)
+	conn, err := t.Dial(address)
 	if err != nil {
 		return err
 	}
+	c.connection.Set(conn)
 
 	c.packet = protocol.New(hostname, login, domain, version)
 	c.packet.Event = int(protocol.EventConnected)
 
 	c.Started.value = true
+	c.stopping = make(chan struct{})
+	c.stopOnce = sync.Once{}
+	c.sent = make(chan struct{})
+
+	c.buffers = pool.NewBuffers(c.BufferSize)
+	c.pool = pool.New(c.Pool)
+	c.pool.Start()
 
 	//отправка пакетов
 	go c.send()
 	//прием пакетов
 	go c.receive()
+	//проверка серверов из списка
+	if c.endpoints != nil {
+		go c.watch()
+	}
 
 	OnStart(c.Handler, c)
 
 	return nil
 }
 
+//Адрес сервера, для unixgram - путь к сокету
+func (c *Client) address(host string, port int) string {
+	if c.Network == transport.NetworkUnixgram {
+		return host
+	}
+	return transport.JoinHostPort(host, port)
+}
+
 //Отправка данных.
 func (c *Client) send() {
 
-	defer c.connection.Close()
+	defer close(c.sent)
+	defer func() {
+		_ = c.connection.Get().Close()
+	}()
 
+	//Клиент останавливается - без ожидания отправляем запросы из очереди,
+	//затем событие отключения. Событие задаем здесь, а не в Stop: сервер
+	//не выполняет запрос из пакета отключения, а ответ сервера может
+	//сбросить событие пакета
+	stopping := false
 	for {
 
-		//Пробегаемся по очереди и заполняем Request,
+		//Пробегаемся по очереди и берем первый неотправленный запрос,
 		//false - выходим из цикла, true - продолжаем крутить
+		var req *protocol.Request
 		c.queue.Range(func(key, value interface{}) bool {
-			item := value.(*QItem)
-			if !item.Sent {
-				c.packet.Request = item.Request
-				item.Sent = true
-				return false
-			}
-			return true
+			req = value.(*QItem).send()
+			return req == nil
 		})
 
+		//Событие пакета меняет обработчик ответов,
+		//поэтому пакет собираем под блокировкой
+		c.packet.Lock()
+		if stopping {
+			c.packet.Event = int(protocol.EventNone)
+			if req == nil {
+				c.packet.Event = int(protocol.EventDisconnect)
+			}
+		}
+		c.packet.Request = req
+		b := c.packet.Marshal()
+		s := ""
+		if c.LogLevel == LogLevelHigh {
+			s = c.packet.String()
+		}
+		event := c.packet.Event
+		c.packet.Request = nil
+		c.packet.Unlock()
+
 		//Пишем данные в порт
-		n, err := c.connection.Write(c.packet.Marshal())
+		conn := c.connection.Get()
+		c.capture(conn, capture.DirectionOut, b)
+		n, err := conn.Write(b)
 		if err != nil {
 			c.Println(err)
 		}
 
 		if c.LogLevel == LogLevelHigh {
-			c.Printf("%s(%d)", c.packet.String(), n)
+			c.Printf("%s(%d)", s, n)
 		}
 
-		//Очищаем Request
-		c.packet.Request = nil
+		//Уведомление отправлено, ответа на него не будет
+		if req != nil && req.Method.IsOneWay() {
+			c.queue.Delete(req.Id)
+		}
 
-		if c.packet.GetEvent() == int(protocol.EventDisconnect) {
+		if event == int(protocol.EventDisconnect) {
 			break
 		}
 
-		time.Sleep(time.Second * 1)
+		timer := time.NewTimer(time.Second)
+		select {
+		case <-timer.C:
+		case <-c.stopping:
+			timer.Stop()
+			stopping = true
+		}
 	}
 }
 
 //Прием данных
 func (c *Client) receive() {
 
-	buffer := make([]byte, c.BufferSize)
+	defer c.pool.Stop()
 
 	for {
 
@@ -187,18 +364,46 @@ func (c *Client) receive() {
 			break
 		}
 
-		n, _, err := c.connection.ReadFromUDP(buffer)
+		buffer := c.buffers.Get()
+
+		conn := c.connection.Get()
+		n, err := conn.Read(*buffer)
 		if err != nil {
+			c.buffers.Put(buffer)
 			continue
 		}
+		c.capture(conn, capture.DirectionIn, (*buffer)[:n])
+		//Ответ текущего сервера из списка, ответы прежнего не учитываем
+		var attached *EndpointStatus
+		if c.endpoints != nil && conn == c.connection.Get() {
+			if status, first := c.endpoints.seen(time.Now()); first {
+				attached = &status
+			}
+		}
 
-		//Передаем данные и разбираем их
-		go func() {
-			err = c.parse(buffer[:n])
+		//Передаем данные в пул и разбираем их,
+		//после разбора буфер возвращаем в пул
+		ok := c.pool.Submit(func() {
+			err := c.parse((*buffer)[:n])
+			c.buffers.Put(buffer)
 			if err != nil {
 				c.Println(err)
 			}
-		}()
+			//Событие подключения к серверу списка после
+			//разбора ответа, когда клиент уже подключен
+			if attached != nil {
+				OnAttached(c.Handler, c, *attached)
+			}
+		})
+		if !ok {
+			c.buffers.Put(buffer)
+			if attached != nil {
+				OnAttached(c.Handler, c, *attached)
+			}
+			if c.LogLevel == LogLevelHigh {
+				c.Println("receive: пакет отброшен, очередь переполнена")
+			}
+		}
 	}
 }
 
@@ -210,6 +415,10 @@ func (c *Client) parse(buffer []byte) error {
 	if err != nil {
 		return err
 	}
+	err = resp.Decompress()
+	if err != nil {
+		return err
+	}
 
 	//Проверяем события
 	switch resp.Event {
@@ -233,84 +442,152 @@ func (c *Client) parse(buffer []byte) error {
 		//c.stopTimer()
 		OnDisconnected(c.Handler, c)
 		return nil
-		/*case int(protocol.EventCheckConnection):
-		//событие проверки активности сервера
-		c.Connected.Set(true)
-		c.restartTimer()
+	//Сервер ответил на проверку
+	case int(protocol.EventCheckConnection):
+		if !c.Connected.Get() {
+			c.Connected.Set(true)
+			OnConnected(c.Handler, c)
+		}
 		OnCheckConnection(c.Handler, c)
-		break*/
 	}
 
 	if c.packet.GetEvent() != int(protocol.EventNone) {
 		c.packet.SetEvent(int(protocol.EventNone))
 	}
 
-	go func() {
-		v, ok := c.queue.Load(resp.Id)
-		if ok {
-			v.(*QItem).Response = resp
-			v.(*QItem).Received = true
-		}
-	}()
+	v, ok := c.queue.Load(resp.Id)
+	if ok {
+		v.(*QItem).receive(resp)
+	} else if resp.Event != int(protocol.EventConnected) && resp.Event != int(protocol.EventCheckConnection) {
+		//событие получения ответа без запроса
+		OnPush(c.Handler, c, resp)
+	}
 
 	return nil
 }
 
 //Отправка запроса на сервер. Добавляем в очередь запрос
-//и запускаем wait функцию
+//и запускаем wait функцию. Для MethodNotify ответ не ждем
+//и возвращаем nil, nil
 func (c *Client) Send(req *protocol.Request) (*protocol.Response, error) {
+	return c.SendContext(context.Background(), req)
+}
+
+//Запрос с ожиданием ответа не дольше чем позволяет ctx. Если ctx завершится
+//раньше ответа, то запрос удаляется из очереди и возвращается ctx.Err()
+func (c *Client) SendContext(ctx context.Context, req *protocol.Request) (*protocol.Response, error) {
+	if e := ctx.Err(); e != nil {
+		return nil, e
+	}
 	req.Id = c.id()
-	c.queue.Store(req.Id, &QItem{
-		Request:  req,
-		Sent:     false,
-		Received: false,
-	})
+	r, span := c.traceSend(c.withMetadata(req))
+	r, e := r.Compressed(c.Compression)
+	if e != nil {
+		traceReceive(span, nil, e)
+		return nil, e
+	}
+	if req.Method.IsOneWay() && !c.Connected.Get() {
+		e = errors.New("Клиент не подключен к серверу")
+		traceReceive(span, nil, e)
+		return nil, e
+	}
+	item := newQItem(r)
+	c.queue.Store(req.Id, item)
+
+	if req.Method.IsOneWay() {
+		traceReceive(span, nil, nil)
+		return nil, nil
+	}
 
 	//Ждем ответа
-	resp := make(chan *protocol.Response)
-	err := make(chan error)
-	go c.wait(req.Id, resp, err)
-	return <-resp, <-err
+	response, e := c.wait(ctx, req.Id, item)
+	traceReceive(span, response, e)
+	return response, e
+}
+
+//Типизированный запрос. in кодируется кодеком contentType,
+//ответ декодируется в out кодеком по ContentType ответа.
+//in и out могут быть nil. Ошибка сервера возвращается как *protocol.Error
+func (c *Client) Call(path string, method protocol.Methods, contentType string, in, out interface{}) error {
+	req, err := newRequest(path, method, contentType, in)
+	if err != nil {
+		return err
+	}
+	return call(context.Background(), c, req, out)
+}
+
+func newRequest(path string, method protocol.Methods, contentType string, in interface{}) (*protocol.Request, error) {
+	req := protocol.NewRequest(path, method)
+	req.ContentType = contentType
+	if in != nil {
+		_, err := req.Encode(contentType, in)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func call(ctx context.Context, clt IClient, req *protocol.Request, out interface{}) error {
+	resp, err := clt.SendContext(ctx, req)
+	if err != nil || req.Method.IsOneWay() {
+		return err
+	}
+	err = resp.Err()
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return resp.Bind(out)
 }
 
 //Ждем ответ от сервера на наш запрос.
-//Если в течении timeout не придет ответ, то возвращаем nil
-func (c *Client) wait(id string, resp chan *protocol.Response, err chan error) {
+//Если в течении timeout не придет ответ, то возвращаем ошибку.
+//Запрос удаляется из очереди и при завершении ctx
+func (c *Client) wait(ctx context.Context, id string, item *QItem) (*protocol.Response, error) {
+
+	defer c.queue.Delete(id)
 
 	if !c.Connected.Get() {
-		resp <- nil
-		err <- errors.New("Клиент не подключен к серверу")
+		return nil, errors.New("Клиент не подключен к серверу")
 	}
 
-	count := 0
-	timer := egotimer.New(1*time.Second, func(t time.Time) bool {
-		if count == c.Timeout {
-			return true
-		}
-		count++
-		return false
-	})
+	timer := time.NewTimer(time.Duration(c.Timeout) * time.Second)
 	defer timer.Stop()
-	go timer.Start()
 
-	received := false
-	for count < c.Timeout && !received {
-		c.queue.Range(func(key, value interface{}) bool {
-			item := value.(*QItem)
-			if key == id && item.Received {
-				resp <- item.Response
-				err <- nil
-				received = true
-			}
-			return !received
-		})
+	select {
+	case <-item.received:
+		return item.response(), nil
+	case <-timer.C:
+		return nil, errors.New("Вышло время ожидания запроса")
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	c.queue.Delete(id)
-	if received {
-		return
+}
+
+func (c *Client) PoolMetrics() pool.Metrics {
+	if c.pool == nil {
+		return pool.Metrics{}
+	}
+	return c.pool.Metrics()
+}
+
+//Копия запроса с метаданными по умолчанию из конфигурации
+func (c *Client) withMetadata(req *protocol.Request) *protocol.Request {
+	if len(c.Metadata) == 0 {
+		return req
 	}
-	resp <- nil
-	err <- errors.New("Вышло время ожидания запроса")
+	r := *req
+	r.Metadata = make(map[string]string, len(c.Metadata)+len(req.Metadata))
+	for key, value := range c.Metadata {
+		r.Metadata[key] = value
+	}
+	for key, value := range req.Metadata {
+		r.Metadata[key] = value
+	}
+	return &r
 }
 
 func (c *Client) id() string {
@@ -325,6 +602,14 @@ func (c *Client) OnDisconnected(handler HandleClient) {
 	c.Handler.OnDisconnected = handler
 }
 
+func (c *Client) OnPush(handler HandlePush) {
+	c.Handler.OnPush = handler
+}
+
+func (c *Client) OnAttached(handler HandleEndpoint) {
+	c.Handler.OnAttached = handler
+}
+
 func (c *Client) OnCheckConnection(handler HandleClient) {
 	c.Handler.OnCheckConnection = handler
 }
@@ -337,10 +622,15 @@ func (c *Client) OnStop(handler HandleClient) {
 	c.Handler.OnStop = handler
 }
 
+//Запросы из очереди и событие отключения отправляются сразу,
+//Stop возвращается после их отправки и закрытия сокета
 func (c *Client) Stop() {
 	OnStop(c.Handler, c)
 	//c.stopTimer()
 	c.Started.Set(false)
 	c.Connected.Set(false)
-	c.packet.SetEvent(int(protocol.EventDisconnect))
+	c.stopOnce.Do(func() {
+		close(c.stopping)
+	})
+	<-c.sent
 }
@@ -0,0 +1,417 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Сервер из списка. Чем меньше Priority, тем выше приоритет,
+//серверы с одинаковым приоритетом перебираются в порядке списка
+type Endpoint struct {
+	Host     string
+	Port     int
+	Priority int
+}
+
+//Переключение между серверами списка Endpoints
+type Failover struct {
+	//Интервал проверки текущего сервера в секундах, 0 - 1
+	Interval int
+	//Сколько проверок подряд без ответа, после чего сервер считается недоступным, 0 - 3
+	Failures int
+	//Возвращаться на сервер с более высоким приоритетом, когда он снова отвечает
+	FailBack bool
+	//Интервал проверки серверов с более высоким приоритетом в секундах, 0 - 30
+	FailBackInterval int
+}
+
+func (f Failover) interval() time.Duration {
+	if f.Interval <= 0 {
+		return time.Second
+	}
+	return time.Duration(f.Interval) * time.Second
+}
+
+func (f Failover) failures() int {
+	if f.Failures <= 0 {
+		return 3
+	}
+	return f.Failures
+}
+
+func (f Failover) failBackInterval() time.Duration {
+	if f.FailBackInterval <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(f.FailBackInterval) * time.Second
+}
+
+//Состояние сервера из списка
+type EndpointStatus struct {
+	Endpoint
+	//host:port, для unixgram - путь к сокету
+	Address string
+	//Сервер ответил на последнюю проверку
+	Healthy bool
+	//Неудачных проверок подряд
+	Failures int
+	//Время последнего ответа сервера
+	LastSeen time.Time
+	//Клиент подключен к этому серверу
+	Current bool
+}
+
+func (e EndpointStatus) String() string {
+	lastSeen := "null"
+	if !e.LastSeen.IsZero() {
+		lastSeen = e.LastSeen.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("address: %s, priority: %d, healthy: %t, failures: %d, current: %t, last_seen: %s",
+		e.Address, e.Priority, e.Healthy, e.Failures, e.Current, lastSeen)
+}
+
+//Список серверов по приоритету и текущий сервер
+type endpoints struct {
+	sync.Mutex
+	list    []*EndpointStatus
+	current int
+	//Получен ответ от текущего сервера после подключения к нему
+	attached bool
+	//Текущий сервер отвечал с прошлой проверки
+	replied bool
+	//Проверок подряд без ответа
+	missed   int
+	failBack time.Time
+}
+
+func newEndpoints(list []Endpoint, address func(host string, port int) string) *endpoints {
+	e := &endpoints{
+		failBack: time.Now(),
+	}
+	for _, endpoint := range list {
+		e.list = append(e.list, &EndpointStatus{
+			Endpoint: endpoint,
+			Address:  address(endpoint.Host, endpoint.Port),
+		})
+	}
+	sort.SliceStable(e.list, func(i, j int) bool {
+		return e.list[i].Priority < e.list[j].Priority
+	})
+	e.list[0].Current = true
+	return e
+}
+
+func (e *endpoints) get(i int) EndpointStatus {
+	e.Lock()
+	defer e.Unlock()
+	return *e.list[i]
+}
+
+func (e *endpoints) statuses() []EndpointStatus {
+	e.Lock()
+	defer e.Unlock()
+	list := make([]EndpointStatus, 0, len(e.list))
+	for _, s := range e.list {
+		list = append(list, *s)
+	}
+	return list
+}
+
+//Ответ текущего сервера, true - первый ответ после подключения к нему
+func (e *endpoints) seen(now time.Time) (EndpointStatus, bool) {
+	e.Lock()
+	defer e.Unlock()
+	s := e.list[e.current]
+	s.Healthy = true
+	s.Failures = 0
+	s.LastSeen = now
+	e.replied = true
+	first := !e.attached
+	e.attached = true
+	return *s, first
+}
+
+//Проверка текущего сервера раз в интервал. send - сервер молчал с прошлой
+//проверки, отправляем ему событие проверки, failed - сервер не ответил
+//на failures проверок подряд
+func (e *endpoints) check(failures int) (send, failed bool) {
+	e.Lock()
+	defer e.Unlock()
+	if e.replied {
+		e.replied = false
+		e.missed = 0
+		return false, false
+	}
+	if e.missed >= failures {
+		e.missed = 0
+		return false, true
+	}
+	e.missed++
+	return true, false
+}
+
+//Текущий сервер не отвечает
+func (e *endpoints) fail() {
+	e.Lock()
+	defer e.Unlock()
+	s := e.list[e.current]
+	s.Healthy = false
+	s.Failures++
+	e.attached = false
+}
+
+func (e *endpoints) failed(i int) {
+	e.Lock()
+	defer e.Unlock()
+	e.list[i].Healthy = false
+	e.list[i].Failures++
+}
+
+//Новый текущий сервер i, возвращаем его состояние и прежний сервер
+func (e *endpoints) attach(i int, now time.Time) (EndpointStatus, int) {
+	e.Lock()
+	defer e.Unlock()
+	prev := e.current
+	e.list[prev].Current = false
+	e.current = i
+	s := e.list[i]
+	s.Current = true
+	s.Healthy = true
+	s.Failures = 0
+	s.LastSeen = now
+	e.replied = true
+	e.missed = 0
+	e.attached = true
+	return *s, prev
+}
+
+//Сервер i приоритетнее текущего
+func (e *endpoints) better(i int) bool {
+	e.Lock()
+	defer e.Unlock()
+	return e.list[i].Priority < e.list[e.current].Priority
+}
+
+//Порядок перебора при отказе: остальные серверы по приоритету,
+//текущий последним
+func (e *endpoints) candidates() []int {
+	e.Lock()
+	defer e.Unlock()
+	list := make([]int, 0, len(e.list))
+	for i := range e.list {
+		if i != e.current {
+			list = append(list, i)
+		}
+	}
+	return append(list, e.current)
+}
+
+//Серверы с более высоким приоритетом, чем у текущего, если пора их проверять
+func (e *endpoints) preferred(now time.Time, interval time.Duration) []int {
+	e.Lock()
+	defer e.Unlock()
+	if !e.attached || now.Sub(e.failBack) < interval {
+		return nil
+	}
+	e.failBack = now
+	var list []int
+	for i, s := range e.list {
+		if s.Priority < e.list[e.current].Priority {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+//Ответивший сервер с более высоким приоритетом
+type candidate struct {
+	i    int
+	conn net.Conn
+}
+
+//Проверяем текущий сервер и переключаемся на другой сервер списка,
+//когда текущий перестал отвечать
+func (c *Client) watch() {
+	ticker := time.NewTicker(c.Failover.interval())
+	defer ticker.Stop()
+	//Серверы с более высоким приоритетом проверяются в отдельной горутине,
+	//чтобы проверка текущего сервера шла по расписанию. Переключение
+	//выполняется только здесь
+	preferred := make(chan candidate, 1)
+	probing := false
+	for {
+		select {
+		case <-ticker.C:
+		case r := <-preferred:
+			probing = false
+			if r.conn == nil {
+				continue
+			}
+			//Пока шла проверка, клиент мог переключиться на другой сервер
+			if !c.endpoints.better(r.i) || !c.switchTo(r.i, r.conn) {
+				_ = r.conn.Close()
+			}
+			continue
+		}
+		if !c.Started.Get() {
+			return
+		}
+		send, failed := c.endpoints.check(c.Failover.failures())
+		switch {
+		case failed:
+			c.failover()
+		//Проверку отправляем сразу, не дожидаясь очередного пакета
+		case send:
+			b := c.eventPacket(protocol.EventCheckConnection)
+			conn := c.connection.Get()
+			c.capture(conn, capture.DirectionOut, b)
+			_, _ = conn.Write(b)
+		}
+		if c.Failover.FailBack && !probing {
+			if list := c.endpoints.preferred(time.Now(), c.Failover.failBackInterval()); len(list) > 0 {
+				probing = true
+				go c.failBack(list, preferred)
+			}
+		}
+	}
+}
+
+//Первый ответивший сервер из list передаем в watch
+func (c *Client) failBack(list []int, preferred chan<- candidate) {
+	for _, i := range list {
+		if conn, ok := c.probeEndpoint(i); ok {
+			preferred <- candidate{i: i, conn: conn}
+			return
+		}
+	}
+	preferred <- candidate{}
+}
+
+func (c *Client) failover() {
+	c.endpoints.fail()
+	if c.Connected.Get() {
+		c.Connected.Set(false)
+		OnDisconnected(c.Handler, c)
+	}
+	//Если не ответил ни один сервер, то текущий остается прежним
+	//и следующая попытка будет после очередных Failures проверок
+	for _, i := range c.endpoints.candidates() {
+		if c.attach(i) {
+			return
+		}
+	}
+}
+
+//Подключаемся к серверу i и переводим на него клиента
+func (c *Client) attach(i int) bool {
+	conn, ok := c.probeEndpoint(i)
+	if !ok {
+		return false
+	}
+	if !c.switchTo(i, conn) {
+		_ = conn.Close()
+		return false
+	}
+	return true
+}
+
+//Проверяем сервер i, не ответивший сервер отмечаем недоступным
+func (c *Client) probeEndpoint(i int) (net.Conn, bool) {
+	conn, err := c.probe(c.endpoints.get(i).Address)
+	if err != nil {
+		c.endpoints.failed(i)
+		if c.LogLevel == LogLevelHigh {
+			c.Println(err)
+		}
+		return nil, false
+	}
+	return conn, true
+}
+
+//Переводим клиента на ответивший сервер i,
+//прежний сервер получает событие отключения
+func (c *Client) switchTo(i int, conn net.Conn) bool {
+	if !c.Started.Get() {
+		return false
+	}
+	old := c.connection.Get()
+	c.connection.Set(conn)
+	status, prev := c.endpoints.attach(i, time.Now())
+	//Тот же сервер уже знает новый адрес клиента, отключаем только другой
+	if prev != i {
+		_, _ = old.Write(c.eventPacket(protocol.EventDisconnect))
+	}
+	_ = old.Close()
+	//Событие подключения уже отправлено при проверке сервера
+	c.packet.CompareAndSetEvent(int(protocol.EventConnected), int(protocol.EventNone))
+	c.Connected.Set(true)
+	OnConnected(c.Handler, c)
+	OnAttached(c.Handler, c, status)
+	return true
+}
+
+//Отправляем событие подключения раз в интервал проверки,
+//пока сервер не ответит, но не больше Failures раз
+func (c *Client) probe(address string) (net.Conn, error) {
+	conn, err := c.dialer.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	b := c.eventPacket(protocol.EventConnected)
+	buffer := c.buffers.Get()
+	defer c.buffers.Put(buffer)
+	for i := 0; i < c.Failover.failures() && c.Started.Get(); i++ {
+		c.capture(conn, capture.DirectionOut, b)
+		_, err = conn.Write(b)
+		if err != nil {
+			break
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(c.Failover.interval()))
+		for {
+			n, err := conn.Read(*buffer)
+			if err != nil {
+				break
+			}
+			c.capture(conn, capture.DirectionIn, (*buffer)[:n])
+			resp := new(protocol.Response)
+			if resp.Unmarshal((*buffer)[:n]) == nil && resp.Event == int(protocol.EventConnected) {
+				_ = conn.SetReadDeadline(time.Time{})
+				return conn, nil
+			}
+		}
+	}
+	_ = conn.Close()
+	return nil, errors.New(fmt.Sprintf("Сервер %s не отвечает", address))
+}
+
+//Пакет клиента с событием и без запроса
+func (c *Client) eventPacket(event protocol.Events) []byte {
+	p := protocol.New(c.packet.Hostname, c.packet.Login, c.packet.Domain, c.packet.Version)
+	p.Event = int(event)
+	return p.Marshal()
+}
+
+//Текущий сервер из списка Endpoints, false - список не задан
+func (c *Client) Endpoint() (EndpointStatus, bool) {
+	if c.endpoints == nil {
+		return EndpointStatus{}, false
+	}
+	c.endpoints.Lock()
+	defer c.endpoints.Unlock()
+	return *c.endpoints.list[c.endpoints.current], true
+}
+
+//Состояние серверов из списка Endpoints по приоритету
+func (c *Client) Health() []EndpointStatus {
+	if c.endpoints == nil {
+		return nil
+	}
+	return c.endpoints.statuses()
+}
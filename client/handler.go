@@ -1,14 +1,24 @@
 package client
 
+import "github.com/egovorukhin/egoudp/protocol"
+
 //События клиента
 type HandleClient func(c *Client)
 
+//Ответ сервера, который клиент не запрашивал
+type HandlePush func(c *Client, resp *protocol.Response)
+
+//Клиент подключился к серверу из списка Endpoints
+type HandleEndpoint func(c *Client, endpoint EndpointStatus)
+
 type Handler struct {
 	OnStart           HandleClient
 	OnStop            HandleClient
 	OnConnected       HandleClient
 	OnDisconnected    HandleClient
 	OnCheckConnection HandleClient
+	OnPush            HandlePush
+	OnAttached        HandleEndpoint
 }
 
 func (h *Handler) HandleStart(c *Client) {
@@ -41,12 +51,26 @@ func (h *Handler) HandleCheckConnection(c *Client) {
 	}
 }
 
+func (h *Handler) HandlePush(c *Client, resp *protocol.Response) {
+	if h.OnPush != nil {
+		go h.OnPush(c, resp)
+	}
+}
+
+func (h *Handler) HandleAttached(c *Client, endpoint EndpointStatus) {
+	if h.OnAttached != nil {
+		go h.OnAttached(c, endpoint)
+	}
+}
+
 type IHandler interface {
 	HandleStart(c *Client)
 	HandleStop(c *Client)
 	HandleConnected(c *Client)
 	HandleDisconnected(c *Client)
 	HandleCheckConnection(c *Client)
+	HandlePush(c *Client, resp *protocol.Response)
+	HandleAttached(c *Client, endpoint EndpointStatus)
 }
 
 func OnStart(handler IHandler, c *Client) {
@@ -68,3 +92,11 @@ func OnDisconnected(handler IHandler, c *Client) {
 func OnCheckConnection(handler IHandler, c *Client) {
 	handler.HandleCheckConnection(c)
 }
+
+func OnPush(handler IHandler, c *Client, resp *protocol.Response) {
+	handler.HandlePush(c, resp)
+}
+
+func OnAttached(handler IHandler, c *Client, endpoint EndpointStatus) {
+	handler.HandleAttached(c, endpoint)
+}
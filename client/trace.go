@@ -0,0 +1,33 @@
+package client
+
+import (
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/trace"
+)
+
+//Начинаем спан отправки запроса и возвращаем копию запроса
+//с контекстом трассировки в метаданных
+func (c *Client) traceSend(req *protocol.Request) (*protocol.Request, *trace.Span) {
+	if c.Tracer == nil {
+		return req, nil
+	}
+	span := c.Tracer.Start("client.Send "+req.Path, trace.Extract(req.Metadata))
+	span.SetAttribute("path", req.Path)
+	span.SetAttribute("method", req.Method.String())
+	r := *req
+	r.Metadata = trace.Inject(req.Metadata, span.SpanContext())
+	return &r, span
+}
+
+//Завершаем спан отправки запроса
+func traceReceive(span *trace.Span, resp *protocol.Response, err error) {
+	if span == nil {
+		return
+	}
+	if err == nil && resp != nil {
+		span.SetAttribute("status_code", resp.StatusCode.String())
+		err = resp.Err()
+	}
+	span.SetError(err)
+	span.Finish()
+}
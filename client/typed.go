@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/trace"
+)
+
+//Типизированный запрос с кодеком json
+func Call[Req, Resp any](ctx context.Context, clt IClient, path string, method protocol.Methods, req Req) (Resp, error) {
+	return CallAs[Req, Resp](ctx, clt, path, method, protocol.ContentTypeJSON, req)
+}
+
+//Типизированный запрос. req кодируется кодеком contentType, ответ декодируется
+//в Resp. Если ctx завершится раньше ответа, то запрос удаляется из очереди
+//и возвращается ctx.Err(). Контекст трассировки из ctx передается серверу
+//в метаданных запроса
+func CallAs[Req, Resp any](ctx context.Context, clt IClient, path string, method protocol.Methods, contentType string, req Req) (Resp, error) {
+	var resp Resp
+	request, err := newRequest(path, method, contentType, req)
+	if err != nil {
+		return resp, err
+	}
+	request.Metadata = trace.Inject(request.Metadata, trace.SpanContextFromContext(ctx))
+	err = call(ctx, clt, request, &resp)
+	return resp, err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func call(args []string) error {
+	fs := flag.NewFlagSet("call", flag.ContinueOnError)
+	var f clientFlags
+	f.register(fs)
+	method := fs.String("method", "get", "метод: none, get, set, create, update, delete, call, notify или число")
+	contentType := fs.String("type", "", "тип данных (ContentType)")
+	data := fs.String("data", "", "данные запроса, \"-\" - читать из stdin")
+	metadata := keyValues{}
+	fs.Var(metadata, "m", "метаданные key=value, можно указывать несколько раз")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: egoudp call [флаги] <path>")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	m, err := protocol.ParseMethod(*method)
+	if err != nil {
+		return err
+	}
+	req := protocol.NewRequest(fs.Arg(0), m)
+	req.ContentType = *contentType
+	if *data == "-" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		req.Data = protocol.ToRunes(string(b))
+	} else if *data != "" {
+		req.Data = protocol.ToRunes(*data)
+	}
+	for key, value := range metadata {
+		req.SetMetadata(key, value)
+	}
+
+	clt, err := f.start(nil)
+	if err != nil {
+		return err
+	}
+	defer f.stop(clt)
+
+	resp, err := clt.Send(req)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		//Уведомление из очереди отправляется при остановке клиента
+		fmt.Println("Уведомление отправлено")
+		return nil
+	}
+	printResponse(resp)
+	return resp.Err()
+}
+
+func printResponse(resp *protocol.Response) {
+	fmt.Printf("status:       %s\n", resp.StatusCode.String())
+	fmt.Printf("event:        %s\n", protocol.EventToString(protocol.Events(resp.Event)))
+	if resp.ContentType != "" {
+		fmt.Printf("content-type: %s\n", resp.ContentType)
+	}
+	printMetadata(resp.Metadata)
+	if resp.Error != nil {
+		fmt.Printf("error:        %s\n", resp.Error.Error())
+	}
+	if len(resp.Data) > 0 {
+		fmt.Println()
+		fmt.Println(resp.Data.String())
+	}
+}
+
+func printMetadata(metadata map[string]string) {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("metadata:     %s=%s\n", key, metadata[key])
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/discovery"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Общие флаги подключения клиента
+type clientFlags struct {
+	network     string
+	host        string
+	discover    string
+	endpoints   string
+	failBack    bool
+	port        int
+	hostname    string
+	login       string
+	domain      string
+	version     string
+	timeout     int
+	bufferSize  int
+	compression string
+	capture     string
+	verbose     bool
+	writer      *capture.Writer
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	//Сервер не принимает пакеты с пустыми полями заголовка
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "egoudp"
+	}
+	login := os.Getenv("USER")
+	if login == "" {
+		login = "egoudp"
+	}
+	fs.StringVar(&f.network, "network", "udp", "сеть: udp, udp4, udp6 или unixgram")
+	fs.StringVar(&f.host, "host", "localhost", "адрес сервера, для unixgram - путь к сокету")
+	fs.IntVar(&f.port, "port", 5655, "порт сервера")
+	fs.StringVar(&f.discover, "discover", "", "найти сервер через группу multicast или широковещательный адрес, например "+discovery.DefaultAddress+", -host не используется")
+	fs.StringVar(&f.endpoints, "endpoints", "", "список серверов host:port через запятую по убыванию приоритета, клиент переключается на следующий, когда текущий не отвечает")
+	fs.BoolVar(&f.failBack, "failback", false, "возвращаться на сервер с более высоким приоритетом из -endpoints")
+	fs.StringVar(&f.hostname, "hostname", hostname, "имя компьютера клиента")
+	fs.StringVar(&f.login, "login", login, "логин клиента")
+	fs.StringVar(&f.domain, "domain", "local", "домен клиента")
+	fs.StringVar(&f.version, "version", "egoudp-cli", "версия клиента")
+	fs.IntVar(&f.timeout, "timeout", 5, "время ожидания подключения и ответа, секунд")
+	fs.IntVar(&f.bufferSize, "buffer", 65535, "размер буфера приема")
+	fs.StringVar(&f.compression, "compression", "", "сжатие запросов: gzip, zstd, snappy")
+	fs.StringVar(&f.capture, "capture", "", "файл захвата пакетов")
+	fs.BoolVar(&f.verbose, "v", false, "выводить отправляемые пакеты")
+}
+
+//Запускаем клиента и ждем подключения к серверу.
+//Захват пакетов закрывается в stop
+func (f *clientFlags) start(onConnected client.HandleClient) (client.IClient, error) {
+	config := client.Config{
+		Network:    f.network,
+		Host:       f.host,
+		Port:       f.port,
+		BufferSize: f.bufferSize,
+		Timeout:    f.timeout,
+		Compression: protocol.Compression{
+			Name: f.compression,
+		},
+	}
+	if f.discover != "" {
+		config.Host = ""
+		config.Discovery = &discovery.Config{Address: f.discover}
+	}
+	if f.endpoints != "" {
+		endpoints, err := parseEndpoints(f.endpoints)
+		if err != nil {
+			return nil, err
+		}
+		config.Endpoints = endpoints
+		config.Failover.FailBack = f.failBack
+	}
+	if f.verbose {
+		config.LogLevel = client.LogLevelHigh
+	}
+	if f.capture != "" {
+		w, err := capture.Create(f.capture)
+		if err != nil {
+			return nil, err
+		}
+		f.writer = w
+		config.Capture = w
+	}
+	clt := client.New(config)
+	if !f.verbose {
+		clt.SetLogger(ioutil.Discard, "", 0)
+	}
+	connected := make(chan struct{}, 1)
+	clt.OnConnected(func(c *client.Client) {
+		select {
+		case connected <- struct{}{}:
+		default:
+		}
+		if onConnected != nil {
+			onConnected(c)
+		}
+	})
+	err := clt.Start(f.hostname, f.login, f.domain, f.version)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-connected:
+		return clt, nil
+	case <-time.After(time.Duration(f.timeout) * time.Second):
+		f.stop(clt)
+		return nil, errors.New("Сервер не ответил на подключение")
+	}
+}
+
+//Приоритет сервера - его позиция в списке
+func parseEndpoints(s string) ([]client.Endpoint, error) {
+	var endpoints []client.Endpoint
+	for i, address := range strings.Split(s, ",") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(address))
+		if err != nil {
+			return nil, err
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Неверный порт сервера %s", address))
+		}
+		endpoints = append(endpoints, client.Endpoint{Host: host, Port: p, Priority: i})
+	}
+	return endpoints, nil
+}
+
+func (f *clientFlags) stop(clt client.IClient) {
+	clt.Stop()
+	if f.writer != nil {
+		err := f.writer.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func decode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ContinueOnError)
+	isHex := fs.Bool("hex", false, "пакет в шестнадцатеричном виде")
+	dump := fs.Bool("dump", false, "вывести разбор по полям со смещениями")
+	isCapture := fs.Bool("capture", false, "файл захвата, разобрать все пакеты")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: egoudp decode [флаги] [файл], без файла - читать из stdin")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *isCapture {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return flag.ErrHelp
+		}
+		return decodeCapture(fs.Arg(0), *dump)
+	}
+
+	var b []byte
+	if fs.NArg() > 0 {
+		b, err = ioutil.ReadFile(fs.Arg(0))
+	} else {
+		b, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	if *isHex {
+		b, err = hex.DecodeString(strings.Join(strings.Fields(string(b)), ""))
+		if err != nil {
+			return err
+		}
+	} else {
+		b = []byte(strings.TrimRight(string(b), "\r\n"))
+	}
+	return decodeDatagram(b, *dump)
+}
+
+//Разбираем все пакеты из файла захвата
+func decodeCapture(path string, dump bool) error {
+	r, err := capture.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for i := 1; ; i++ {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("#%d %s %s %s\n", i, rec.Time.Format("2006-01-02 15:04:05.000000"), rec.Direction, rec.Addr)
+		err = decodeDatagram(rec.Data, dump)
+		if err != nil {
+			fmt.Println(err)
+		}
+		fmt.Println()
+	}
+}
+
+func decodeDatagram(b []byte, dump bool) error {
+	if dump {
+		d := protocol.Dissect(b)
+		fmt.Print(d.String())
+		return d.Err
+	}
+
+	//Пакет клиента содержит заголовок, ответ сервера - нет.
+	//Пробуем разобрать как пакет, затем как ответ
+	packet := new(protocol.Packet)
+	errPacket := packet.Unmarshal(b)
+	if errPacket == nil && !packet.Header.IsNil() {
+		printPacket(packet)
+		return nil
+	}
+	resp := new(protocol.Response)
+	errResponse := resp.Unmarshal(b)
+	if errPacket == nil && errResponse != nil {
+		printPacket(packet)
+		return nil
+	}
+	if errResponse == nil {
+		fmt.Println("Ответ сервера")
+		if resp.Encoding != "" {
+			fmt.Printf("encoding:     %s\n", resp.Encoding)
+			if err := resp.Decompress(); err != nil {
+				fmt.Printf("decompress:   %v\n", err)
+			}
+		}
+		printResponse(resp)
+		return nil
+	}
+	//Показываем где именно разбор споткнулся
+	fmt.Print(protocol.Dissect(b).String())
+	return fmt.Errorf("не удалось разобрать пакет: как пакет клиента - %v, как ответ сервера - %v", errPacket, errResponse)
+}
+
+func printPacket(p *protocol.Packet) {
+	fmt.Println("Пакет клиента")
+	fmt.Printf("hostname:     %s\n", p.Hostname)
+	fmt.Printf("login:        %s\n", p.Login)
+	fmt.Printf("domain:       %s\n", p.Domain)
+	fmt.Printf("version:      %s\n", p.Version)
+	fmt.Printf("event:        %s\n", protocol.EventToString(protocol.Events(p.Event)))
+	req := p.Request
+	if req == nil {
+		return
+	}
+	fmt.Println()
+	fmt.Printf("path:         %s\n", req.Path)
+	fmt.Printf("method:       %s\n", req.Method.String())
+	fmt.Printf("id:           %s\n", req.Id)
+	if req.ContentType != "" {
+		fmt.Printf("content-type: %s\n", req.ContentType)
+	}
+	if req.Encoding != "" {
+		fmt.Printf("encoding:     %s\n", req.Encoding)
+	}
+	if req.AcceptEncoding != "" {
+		fmt.Printf("accept:       %s\n", req.AcceptEncoding)
+	}
+	printMetadata(req.Metadata)
+	if req.Encoding != "" {
+		if err := req.Decompress(); err != nil {
+			fmt.Printf("decompress:   %v\n", err)
+		}
+	}
+	if len(req.Data) > 0 {
+		fmt.Println()
+		fmt.Println(req.Data.String())
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/egovorukhin/egoudp/discovery"
+)
+
+func discover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	var config discovery.Config
+	fs.StringVar(&config.Address, "address", discovery.DefaultAddress, "группа multicast или широковещательный адрес")
+	fs.StringVar(&config.Interface, "interface", "", "сетевой интерфейс для multicast")
+	fs.StringVar(&config.Name, "name", "", "имя сервера, пусто - любой")
+	fs.DurationVar(&config.Timeout, "timeout", discovery.DefaultTimeout, "время ожидания ответов")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	list, err := discovery.Discover(config)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("серверы не найдены по адресу %s", config.Address)
+	}
+	for _, a := range list {
+		fmt.Printf("%-20s %-24s %-6s %-8v %v\n", a.Name, a.Address(), a.Network, a.RTT.Round(time.Microsecond), a.Capabilities)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func listen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ContinueOnError)
+	var f clientFlags
+	f.register(fs)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	now := func() string {
+		return time.Now().Format("15:04:05.000")
+	}
+	clt, err := f.start(func(c *client.Client) {
+		if e, ok := c.Endpoint(); ok {
+			fmt.Printf("%s connected: %s\n", now(), e.Address)
+			return
+		}
+		if c.Discovered != nil {
+			fmt.Printf("%s connected: %s\n", now(), c.Discovered.String())
+			return
+		}
+		fmt.Printf("%s connected: %s:%d\n", now(), f.host, f.port)
+	})
+	if err != nil {
+		return err
+	}
+	defer f.stop(clt)
+	clt.OnDisconnected(func(c *client.Client) {
+		fmt.Printf("%s disconnected\n", now())
+	})
+	clt.OnPush(func(c *client.Client, resp *protocol.Response) {
+		fmt.Printf("%s push: %s\n", now(), resp.String())
+	})
+
+	<-interrupted()
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const usage = `egoudp - отладка сервера и клиента egoudp
+
+Использование:
+  egoudp <команда> [флаги]
+
+Команды:
+  call      отправить запрос и вывести ответ
+  listen    подключиться к серверу и выводить события и ответы без запроса
+  serve     запустить сервер с маршрутами echo
+  decode    разобрать захваченный пакет
+  replay    воспроизвести файл захвата на сервер
+  discover  найти серверы в сети
+
+Флаги команды: egoudp <команда> -h
+`
+
+type command struct {
+	name string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"call", call},
+	{"listen", listen},
+	{"serve", serve},
+	{"decode", decode},
+	{"replay", replay},
+	{"discover", discover},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(os.Args[2:])
+			if err == flag.ErrHelp {
+				os.Exit(2)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	if name == "-h" || name == "help" {
+		fmt.Print(usage)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Неизвестная команда - %s\n\n%s", name, usage)
+	os.Exit(2)
+}
+
+//Ждем Ctrl+C
+func interrupted() <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	return ch
+}
+
+//Флаг вида -m key=value, можно указывать несколько раз
+type keyValues map[string]string
+
+func (kv keyValues) String() string {
+	s := make([]string, 0, len(kv))
+	for key, value := range kv {
+		s = append(s, key+"="+value)
+	}
+	return strings.Join(s, ",")
+}
+
+func (kv keyValues) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return fmt.Errorf("ожидается key=value - %s", s)
+	}
+	kv[s[:i]] = s[i+1:]
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	host := fs.String("host", "localhost", "адрес сервера")
+	port := fs.Int("port", 5655, "порт сервера")
+	direction := fs.String("direction", "in", "какие пакеты отправлять: in - захват на сервере, out - захват на клиенте")
+	speed := fs.Float64("speed", 0, "скорость относительно захвата, 1 - как при захвате, 0 - без пауз")
+	wait := fs.Duration("wait", time.Second, "время ожидания ответов")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: egoudp replay [флаги] <файл захвата>")
+		fs.PrintDefaults()
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	config := capture.ReplayConfig{
+		Speed: *speed,
+		Wait:  *wait,
+	}
+	switch *direction {
+	case "in":
+		config.Direction = capture.DirectionIn
+	case "out":
+		config.Direction = capture.DirectionOut
+	default:
+		return fmt.Errorf("неизвестное направление - %s", *direction)
+	}
+
+	r, err := capture.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(*host, strconv.Itoa(*port)))
+	if err != nil {
+		return err
+	}
+	result, err := capture.Replay(records, addr, config)
+	if err != nil {
+		return err
+	}
+	for _, rec := range result.Responses {
+		resp := new(protocol.Response)
+		if err := resp.Unmarshal(rec.Data); err != nil {
+			fmt.Printf("%s: %v: %q\n", rec.Addr, err, rec.Data)
+			continue
+		}
+		fmt.Printf("%s: %s\n", rec.Addr, resp.String())
+	}
+	fmt.Printf("records: %d, sent: %d, received: %d\n", len(records), result.Sent, result.Received)
+	return nil
+}
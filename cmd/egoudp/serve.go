@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/egovorukhin/egoudp/admin"
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/discovery"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+)
+
+var echoMethods = []protocol.Methods{
+	protocol.MethodNone,
+	protocol.MethodGet,
+	protocol.MethodSet,
+	protocol.MethodCreate,
+	protocol.MethodUpdate,
+	protocol.MethodDelete,
+	protocol.MethodCall,
+	protocol.MethodNotify,
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	network := fs.String("network", "udp", "сеть: udp, udp4, udp6 или unixgram")
+	host := fs.String("host", "", "адрес интерфейса, пусто - все интерфейсы")
+	socket := fs.String("socket", "", "путь к сокету для unixgram")
+	discoveryAddr := fs.String("discovery", "", "отвечать на поиск серверов на группе multicast или широковещательном адресе, например "+discovery.DefaultAddress)
+	name := fs.String("name", "", "имя сервера для поиска, по умолчанию имя компьютера")
+	port := fs.Int("port", 5655, "порт сервера")
+	path := fs.String("path", "echo", "маршрут echo, отвечает данными запроса")
+	timeout := fs.Int("disconnect", 30, "время до отключения клиента без пакетов, секунд")
+	bufferSize := fs.Int("buffer", 65535, "размер буфера приема")
+	compression := fs.String("compression", "", "сжатие ответов: gzip, zstd, snappy")
+	adminAddr := fs.String("admin", "", "адрес HTTP API, например :5656, без -admin-user только 127.0.0.1")
+	adminUser := fs.String("admin-user", "", "пользователь HTTP API")
+	adminPassword := fs.String("admin-password", "", "пароль HTTP API")
+	capturePath := fs.String("capture", "", "файл захвата пакетов, воспроизводится командой replay")
+	verbose := fs.Bool("v", false, "выводить отправляемые ответы")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if (*adminUser == "") != (*adminPassword == "") {
+		return errors.New("-admin-user и -admin-password задаются вместе")
+	}
+	if *adminAddr != "" {
+		*adminAddr, err = adminAddress(*adminAddr, *adminUser)
+		if err != nil {
+			return err
+		}
+	}
+
+	config := server.Config{
+		Network:           *network,
+		Host:              *host,
+		Port:              *port,
+		BufferSize:        *bufferSize,
+		DisconnectTimeout: *timeout,
+		Compression: protocol.Compression{
+			Name: *compression,
+		},
+	}
+	if *network == "unixgram" {
+		config.Host = *socket
+	}
+	if *discoveryAddr != "" {
+		config.Discovery = &discovery.Config{Address: *discoveryAddr, Name: *name}
+	}
+	if *verbose {
+		config.LogLevel = server.LogLevelHigh
+	}
+	if *capturePath != "" {
+		w, err := capture.Create(*capturePath)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		config.Capture = w
+	}
+	srv := server.New(config)
+	now := func() string {
+		return time.Now().Format("15:04:05.000")
+	}
+	srv.OnConnected(func(c *server.Connection) {
+		fmt.Printf("%s connected: %s\n", now(), c.String())
+	})
+	srv.OnDisconnected(func(c *server.Connection) {
+		fmt.Printf("%s disconnected: %s\n", now(), c.Hostname)
+	})
+	for _, method := range echoMethods {
+		srv.SetRoute(*path, method, func(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
+			fmt.Printf("%s %s: %s\n", now(), c.Hostname, req.String())
+			for key, value := range req.Metadata {
+				resp.SetMetadata(key, value)
+			}
+			c.Send1(resp.SetContentType(req.ContentType).SetData(protocol.StatusCodeOK, req.Data))
+		})
+	}
+	err = srv.Start()
+	if err != nil {
+		return err
+	}
+	defer srv.Stop()
+	fmt.Printf("%s started: %s, route: %s\n", now(), srv.(*server.Server).LocalAddr(), *path)
+
+	if *adminAddr != "" {
+		go func() {
+			err := http.ListenAndServe(*adminAddr, admin.New(srv, admin.Config{
+				Username: *adminUser,
+				Password: *adminPassword,
+			}))
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+
+	<-interrupted()
+	return nil
+}
+
+//HTTP API без авторизации слушает только loopback: адрес без хоста
+//заменяем на 127.0.0.1, остальные адреса не принимаем
+func adminAddress(addr, user string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if user != "" {
+		return addr, nil
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return addr, nil
+	}
+	return "", errors.New(fmt.Sprintf("HTTP API на адресе %s требует -admin-user и -admin-password", addr))
+}
@@ -0,0 +1,280 @@
+package discovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+//Группа multicast и порт по умолчанию
+const DefaultAddress = "239.255.86.86:5654"
+
+//Время ожидания ответов по умолчанию
+const DefaultTimeout = time.Second
+
+const service = "egoudp"
+
+//Настройка обнаружения. Address - группа multicast (239.255.86.86:5654, [ff02::86]:5654)
+//или широковещательный адрес (255.255.255.255:5654, 192.168.1.255:5654)
+type Config struct {
+	Address string
+	//Сетевой интерфейс для multicast, пусто - по умолчанию
+	Interface string
+	//Имя сервера. У клиента - имя искомого сервера, пусто - любой
+	Name string
+	//Возможности сервера, передаются клиенту в ответе
+	Capabilities []string
+	//Сколько клиент ждет ответов, 0 - DefaultTimeout
+	Timeout time.Duration
+}
+
+func (c Config) address() string {
+	if c.Address == "" {
+		return DefaultAddress
+	}
+	return c.Address
+}
+
+func (c Config) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+func (c Config) iface() (*net.Interface, error) {
+	if c.Interface == "" {
+		return nil, nil
+	}
+	return net.InterfaceByName(c.Interface)
+}
+
+//Запрос клиента
+type Probe struct {
+	Service string `json:"service"`
+	Name    string `json:"name,omitempty"`
+}
+
+//Ответ сервера. Если Host пустой, то сервер доступен по адресу,
+//с которого пришел ответ
+type Announcement struct {
+	Service      string   `json:"service"`
+	Name         string   `json:"name"`
+	Host         string   `json:"host,omitempty"`
+	Port         int      `json:"port"`
+	Network      string   `json:"network"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	//Адрес отправителя ответа и время от запроса до ответа, заполняет клиент
+	From *net.UDPAddr  `json:"-"`
+	RTT  time.Duration `json:"-"`
+}
+
+//Адрес сервера host:port
+func (a *Announcement) Address() string {
+	host := a.Host
+	if host == "" && a.From != nil {
+		host = a.From.IP.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(a.Port))
+}
+
+func (a *Announcement) Has(capability string) bool {
+	for _, c := range a.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Announcement) String() string {
+	return fmt.Sprintf("name: %s, address: %s, network: %s, capabilities: %v, rtt: %v",
+		a.Name, a.Address(), a.Network, a.Capabilities, a.RTT)
+}
+
+//Отвечает на запросы обнаружения
+type Responder struct {
+	conn         *net.UDPConn
+	announcement []byte
+	name         string
+}
+
+//Открываем сокет на адресе обнаружения. Для группы multicast
+//подключаемся к ней на интерфейсе из конфигурации
+func Listen(config Config, announcement Announcement) (*Responder, error) {
+	addr, err := net.ResolveUDPAddr("udp", config.address())
+	if err != nil {
+		return nil, err
+	}
+	var conn *net.UDPConn
+	if addr.IP.IsMulticast() {
+		ifi, err := config.iface()
+		if err != nil {
+			return nil, err
+		}
+		conn, err = net.ListenMulticastUDP(network(addr), ifi, addr)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		conn, err = net.ListenUDP(network(addr), &net.UDPAddr{Port: addr.Port})
+		if err != nil {
+			return nil, err
+		}
+	}
+	announcement.Service = service
+	if announcement.Name == "" {
+		announcement.Name = config.Name
+	}
+	announcement.Capabilities = append(announcement.Capabilities, config.Capabilities...)
+	b, err := json.Marshal(announcement)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &Responder{
+		conn:         conn,
+		announcement: b,
+		name:         announcement.Name,
+	}, nil
+}
+
+func network(addr *net.UDPAddr) string {
+	if addr.IP.To4() == nil && addr.IP != nil {
+		return "udp6"
+	}
+	return "udp4"
+}
+
+func (r *Responder) LocalAddr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+//Отвечаем на запросы до закрытия
+func (r *Responder) Serve() error {
+	buffer := make([]byte, 1024)
+	for {
+		n, addr, err := r.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			continue
+		}
+		var probe Probe
+		if json.Unmarshal(buffer[:n], &probe) != nil || probe.Service != service {
+			continue
+		}
+		if probe.Name != "" && probe.Name != r.name {
+			continue
+		}
+		_, _ = r.conn.WriteToUDP(r.announcement, addr)
+	}
+}
+
+func (r *Responder) Close() error {
+	return r.conn.Close()
+}
+
+//Ищем серверы: отправляем запрос на адрес обнаружения три раза
+//за время ожидания и собираем ответы. Серверы возвращаются
+//по возрастанию времени ответа
+func Discover(config Config) ([]*Announcement, error) {
+	addr, err := net.ResolveUDPAddr("udp", config.address())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP(network(addr), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if addr.IP.IsMulticast() {
+		err = multicastInterface(conn, addr, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	probe, err := json.Marshal(Probe{Service: service, Name: config.Name})
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	timeout := config.timeout()
+	deadline := start.Add(timeout)
+	found := map[string]*Announcement{}
+	buffer := make([]byte, 4096)
+	for i := 0; i < 3; i++ {
+		_, err = conn.WriteToUDP(probe, addr)
+		if err != nil {
+			return nil, err
+		}
+		next := start.Add(timeout * time.Duration(i+1) / 3)
+		if i == 2 {
+			next = deadline
+		}
+		_ = conn.SetReadDeadline(next)
+		for {
+			n, from, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				break
+			}
+			a := new(Announcement)
+			if json.Unmarshal(buffer[:n], a) != nil || a.Service != service {
+				continue
+			}
+			if config.Name != "" && a.Name != config.Name {
+				continue
+			}
+			a.From = from
+			a.RTT = time.Since(start)
+			if _, ok := found[a.Address()]; !ok {
+				found[a.Address()] = a
+			}
+		}
+	}
+
+	list := make([]*Announcement, 0, len(found))
+	for _, a := range found {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].RTT < list[j].RTT
+	})
+	return list, nil
+}
+
+//Первый ответивший сервер
+func Find(config Config) (*Announcement, error) {
+	list, err := Discover(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.New(fmt.Sprintf("Сервер не найден по адресу %s", config.address()))
+	}
+	return list[0], nil
+}
+
+func multicastInterface(conn *net.UDPConn, addr *net.UDPAddr, config Config) error {
+	ifi, err := config.iface()
+	if err != nil || ifi == nil {
+		return err
+	}
+	if addr.IP.To4() != nil {
+		p := ipv4.NewPacketConn(conn)
+		_ = p.SetMulticastLoopback(true)
+		return p.SetMulticastInterface(ifi)
+	}
+	p := ipv6.NewPacketConn(conn)
+	_ = p.SetMulticastLoopback(true)
+	return p.SetMulticastInterface(ifi)
+}
@@ -0,0 +1,141 @@
+package discovery_test
+
+import (
+	"io/ioutil"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/discovery"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+)
+
+func freePort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+//Группа multicast на loopback интерфейсе
+func loopback(t *testing.T) discovery.Config {
+	config := discovery.Config{
+		Address: "239.255.86.86:" + strconv.Itoa(freePort(t)),
+		Timeout: 300 * time.Millisecond,
+	}
+	ifaces, _ := net.Interfaces()
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagLoopback != 0 && ifi.Flags&net.FlagUp != 0 {
+			config.Interface = ifi.Name
+			break
+		}
+	}
+	return config
+}
+
+func listen(t *testing.T, config discovery.Config, a discovery.Announcement) {
+	r, err := discovery.Listen(config, a)
+	if err != nil {
+		t.Skipf("multicast недоступен: %v", err)
+	}
+	go r.Serve()
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	config := loopback(t)
+	listen(t, config, discovery.Announcement{Name: "a", Port: 5001, Network: "udp", Capabilities: []string{"trace"}})
+	listen(t, config, discovery.Announcement{Name: "b", Host: "127.0.0.1", Port: 5002, Network: "udp"})
+
+	list, err := discovery.Discover(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("найдено: %v", list)
+	}
+	for _, a := range list {
+		switch a.Name {
+		case "a":
+			if !a.Has("trace") || a.From == nil || a.Address() != net.JoinHostPort(a.From.IP.String(), "5001") {
+				t.Errorf("%s", a)
+			}
+		case "b":
+			if a.Address() != "127.0.0.1:5002" {
+				t.Errorf("%s", a)
+			}
+		}
+	}
+
+	config.Name = "b"
+	a, err := discovery.Find(config)
+	if err != nil || a.Name != "b" {
+		t.Fatalf("Find: %v, %v", a, err)
+	}
+}
+
+//Обычный адрес вместо группы: так же работает широковещательный адрес
+func TestUnicast(t *testing.T) {
+	config := discovery.Config{
+		Address: "127.0.0.1:" + strconv.Itoa(freePort(t)),
+		Name:    "srv",
+		Timeout: 300 * time.Millisecond,
+	}
+	listen(t, config, discovery.Announcement{Port: 5001, Network: "udp"})
+	a, err := discovery.Find(config)
+	if err != nil || a.Name != "srv" || a.Address() != "127.0.0.1:5001" {
+		t.Fatalf("Find: %v, %v", a, err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	config := loopback(t)
+	config.Timeout = 100 * time.Millisecond
+	if a, err := discovery.Find(config); err == nil {
+		t.Fatalf("найден %s", a)
+	}
+}
+
+//Клиент без Host находит сервер и подключается к нему
+func TestClient(t *testing.T) {
+	config := loopback(t)
+	config.Name = "office"
+	srv := server.New(server.Config{
+		BufferSize:        1024,
+		DisconnectTimeout: 30,
+		Compression:       protocol.Compression{Name: protocol.EncodingGzip},
+		Discovery:         &config,
+	})
+	srv.SetLogger(ioutil.Discard, "", 0)
+	if err := srv.Start(); err != nil {
+		t.Skipf("multicast недоступен: %v", err)
+	}
+	defer srv.Stop()
+
+	clt := client.New(client.Config{BufferSize: 1024, Timeout: 3, Discovery: &config}).(*client.Client)
+	clt.SetLogger(ioutil.Discard, "", 0)
+	connected := make(chan bool, 1)
+	clt.OnConnected(func(c *client.Client) {
+		connected <- true
+	})
+	if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	defer clt.Stop()
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("клиент не подключился")
+	}
+	a := clt.Discovered
+	if a.Name != "office" || a.Port != srv.(*server.Server).LocalAddr().(*net.UDPAddr).Port || !a.Has("compression:gzip") {
+		t.Errorf("%s", a)
+	}
+}
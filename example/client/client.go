@@ -1,8 +1,7 @@
 package main
 
 import (
-	"encoding/json"
-	"encoding/xml"
+	"context"
 	"fmt"
 	"github.com/egovorukhin/egoudp/client"
 	"github.com/egovorukhin/egoudp/protocol"
@@ -76,6 +75,15 @@ func main() {
 			}
 			fmt.Println(w)
 			break
+		case "season":
+			resp, err := client.Call[SeasonRequest, SeasonResponse](context.Background(), clt,
+				"season", protocol.MethodGet, SeasonRequest{Month: "Январь"})
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println(resp.Season)
+			break
 		case "stop":
 			clt.Stop()
 			fmt.Println("Клиент остановлен")
@@ -111,24 +119,13 @@ func Hi(c client.IClient) ([]rune, error) {
 }
 
 func Winter(c client.IClient, v interface{}) error {
-	req := protocol.NewRequest("winter", protocol.MethodGet)
-	resp, err := c.Send(req)
-	if err != nil {
-		return err
-	}
-	switch resp.ContentType {
-	case "json":
-		err = json.Unmarshal(resp.Data.ToByte(), v)
-		if err != nil {
-			return err
-		}
-		break
-	case "xml":
-		err = xml.Unmarshal(resp.Data.ToByte(), v)
-		if err != nil {
-			return err
-		}
-	}
+	return c.Call("winter", protocol.MethodGet, protocol.ContentTypeJSON, nil, v)
+}
+
+type SeasonRequest struct {
+	Month string `json:"month"`
+}
 
-	return nil
+type SeasonResponse struct {
+	Season string `json:"season"`
 }
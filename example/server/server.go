@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/egovorukhin/egoudp/admin"
 	"github.com/egovorukhin/egoudp/protocol"
 	"github.com/egovorukhin/egoudp/server"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -28,6 +32,18 @@ func main() {
 	srv.OnDisconnected(OnDisconnected)
 	srv.SetRoute("hi", protocol.MethodNone, Hi)
 	srv.SetRoute("winter", protocol.MethodGet, Winter)
+	server.Handle(srv, "season", protocol.MethodGet, Season)
+
+	//Управление сервером по http: curl -u admin:admin localhost:5656/connections
+	go func() {
+		err := http.ListenAndServe(":5656", admin.New(srv, admin.Config{
+			Username: "admin",
+			Password: "admin",
+		}))
+		if err != nil {
+			fmt.Println(err)
+		}
+	}()
 
 	for {
 		var input string
@@ -94,10 +110,36 @@ func Hi(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
 
 func Winter(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
 	//JSON
-	data := `["Декабрь", "Январь", "Февраль"]`
-	resp = resp.SetData(protocol.StatusCodeOK, []rune(data)).SetContentType("json")
-	_, err := c.Send(resp)
+	data := []string{"Декабрь", "Январь", "Февраль"}
+	r, err := resp.Encode(protocol.ContentTypeJSON, data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	_, err = c.Send(r)
 	if err != nil {
 		fmt.Println(err)
 	}
 }
+
+type SeasonRequest struct {
+	Month string `json:"month"`
+}
+
+type SeasonResponse struct {
+	Season string `json:"season"`
+}
+
+func Season(ctx context.Context, c *server.Connection, req SeasonRequest) (SeasonResponse, error) {
+	switch req.Month {
+	case "Декабрь", "Январь", "Февраль":
+		return SeasonResponse{Season: "Зима"}, nil
+	case "Март", "Апрель", "Май":
+		return SeasonResponse{Season: "Весна"}, nil
+	case "Июнь", "Июль", "Август":
+		return SeasonResponse{Season: "Лето"}, nil
+	case "Сентябрь", "Октябрь", "Ноябрь":
+		return SeasonResponse{Season: "Осень"}, nil
+	}
+	return SeasonResponse{}, errors.New(fmt.Sprintf("Неизвестный месяц - %s", req.Month))
+}
@@ -1,8 +1,16 @@
 module github.com/egovorukhin/egoudp
 
-go 1.15
+go 1.18
 
 require (
 	github.com/egovorukhin/egotimer v0.0.2
+	github.com/golang/snappy v0.0.4
 	github.com/google/uuid v1.2.0
+	github.com/klauspost/compress v1.16.7
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.20.0
+	google.golang.org/protobuf v1.33.0
 )
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -0,0 +1,30 @@
+package pool
+
+import "sync"
+
+//Пул буферов фиксированного размера для приема пакетов
+type Buffers struct {
+	size int
+	pool sync.Pool
+}
+
+func NewBuffers(size int) *Buffers {
+	b := &Buffers{
+		size: size,
+	}
+	b.pool.New = func() interface{} {
+		buf := make([]byte, b.size)
+		return &buf
+	}
+	return b
+}
+
+func (b *Buffers) Get() *[]byte {
+	return b.pool.Get().(*[]byte)
+}
+
+//Возвращаем буфер в пул, после этого буфер использовать нельзя
+func (b *Buffers) Put(buf *[]byte) {
+	*buf = (*buf)[:b.size]
+	b.pool.Put(buf)
+}
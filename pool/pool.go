@@ -0,0 +1,153 @@
+package pool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//Поведение при переполненной очереди
+type Policy int
+
+const (
+	//Ждем освобождения места в очереди
+	PolicyBlock Policy = iota
+	//Отбрасываем задачу
+	PolicyDrop
+)
+
+const DefaultQueueSize = 1024
+
+type Config struct {
+	//Количество обработчиков, по умолчанию runtime.NumCPU()
+	Workers int
+	//Размер очереди, по умолчанию DefaultQueueSize
+	QueueSize int
+	Policy    Policy
+}
+
+type Metrics struct {
+	Workers    int
+	QueueSize  int
+	QueueDepth int
+	Processed  uint64
+	Dropped    uint64
+}
+
+//Пул обработчиков с ограниченной очередью задач
+type Pool struct {
+	Config
+	sync.RWMutex
+	queue     chan func()
+	wg        sync.WaitGroup
+	done      chan struct{}
+	closed    bool
+	processed uint64
+	dropped   uint64
+	//Закрывается в Stop, освобождает ожидающие Submit
+	closing chan struct{}
+	//Submit, которые еще могут записать в очередь
+	submits sync.WaitGroup
+}
+
+func New(config Config) *Pool {
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	return &Pool{
+		Config:  config,
+		queue:   make(chan func(), config.QueueSize),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+}
+
+func (p *Pool) Start() {
+	p.wg.Add(p.Workers)
+	for i := 0; i < p.Workers; i++ {
+		go p.work()
+	}
+	go func() {
+		p.wg.Wait()
+		close(p.done)
+	}()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for task := range p.queue {
+		task()
+		atomic.AddUint64(&p.processed, 1)
+	}
+}
+
+//Ставим задачу в очередь. Возвращает false,
+//если задача отброшена или пул остановлен.
+//С PolicyBlock ожидание места в очереди прерывается остановкой пула
+func (p *Pool) Submit(task func()) bool {
+	p.RLock()
+	if p.closed {
+		p.RUnlock()
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+	p.submits.Add(1)
+	p.RUnlock()
+	defer p.submits.Done()
+
+	if p.Policy == PolicyDrop {
+		select {
+		case p.queue <- task:
+			return true
+		default:
+			atomic.AddUint64(&p.dropped, 1)
+			return false
+		}
+	}
+	select {
+	case p.queue <- task:
+		return true
+	case <-p.closing:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+//Останавливаем пул. Задачи, которые уже в очереди, будут выполнены,
+//ожидающие места в очереди отбрасываются
+func (p *Pool) Stop() {
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.closing)
+	p.Unlock()
+	//Очередь закрываем, когда в нее уже никто не пишет
+	p.submits.Wait()
+	close(p.queue)
+}
+
+//Ждем завершения обработчиков после Stop
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+//Закрывается, когда после Stop выполнены все задачи из очереди
+func (p *Pool) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Pool) Metrics() Metrics {
+	return Metrics{
+		Workers:    p.Workers,
+		QueueSize:  p.QueueSize,
+		QueueDepth: len(p.queue),
+		Processed:  atomic.LoadUint64(&p.processed),
+		Dropped:    atomic.LoadUint64(&p.dropped),
+	}
+}
@@ -0,0 +1,108 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDrop(t *testing.T) {
+	p := New(Config{
+		Workers:   1,
+		QueueSize: 1,
+		Policy:    PolicyDrop,
+	})
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Start()
+	defer p.Stop()
+
+	//Занимаем единственный обработчик
+	p.Submit(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	//Заполняем очередь
+	if !p.Submit(func() {}) {
+		t.Error("задача должна попасть в очередь")
+	}
+	//Очередь полна - задача отбрасывается
+	if p.Submit(func() {}) {
+		t.Error("задача должна быть отброшена")
+	}
+	m := p.Metrics()
+	if m.QueueDepth != 1 || m.Dropped != 1 {
+		t.Errorf("metrics: %+v", m)
+	}
+	close(block)
+}
+
+func TestBlock(t *testing.T) {
+	p := New(Config{
+		Workers:   2,
+		QueueSize: 1,
+	})
+	p.Start()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		if !p.Submit(wg.Done) {
+			t.Fatal("задача не должна отбрасываться")
+		}
+	}
+	wg.Wait()
+	p.Stop()
+	p.Wait()
+
+	if p.Submit(func() {}) {
+		t.Error("пул остановлен")
+	}
+	m := p.Metrics()
+	if m.Processed != 100 || m.Dropped != 1 {
+		t.Errorf("metrics: %+v", m)
+	}
+}
+
+//Stop не ждет Submit, заблокированный на полной очереди
+func TestStopBlocked(t *testing.T) {
+	p := New(Config{
+		Workers:   1,
+		QueueSize: 1,
+	})
+	block := make(chan struct{})
+	started := make(chan struct{})
+	p.Start()
+	p.Submit(func() {
+		close(started)
+		<-block
+	})
+	<-started
+	p.Submit(func() {})
+
+	submitted := make(chan bool)
+	go func() {
+		submitted <- p.Submit(func() {})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop заблокирован")
+	}
+	if <-submitted {
+		t.Error("задача должна быть отброшена")
+	}
+	close(block)
+	p.Wait()
+	if m := p.Metrics(); m.Processed != 2 || m.Dropped != 1 {
+		t.Errorf("metrics: %+v", m)
+	}
+}
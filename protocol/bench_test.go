@@ -0,0 +1,164 @@
+package protocol
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func benchPacket() *Packet {
+	return &Packet{
+		Header: Header{
+			Hostname: "COMPUTER-0001",
+			Login:    "user",
+			Domain:   "HQ.DOMAIN.COM",
+			Version:  "3.3.6",
+			Event:    int(EventNone),
+		},
+		Request: &Request{
+			Path:        "winter",
+			Id:          "9b6f0f4c3a3e4c4b8d6f1e2a3b4c5d6e",
+			Method:      MethodGet,
+			ContentType: "json",
+			Data:        ToRunes(`["Декабрь", "Январь", "Февраль"]`),
+		},
+	}
+}
+
+//Прежний разбор поля: каждый вызов переводит весь остаток буфера в руны
+func legacyFindField(b []byte) (string, []byte, error) {
+	for i, value := range b {
+		if value == ':' {
+			n, err := strconv.Atoi(string(b[:i]))
+			if err != nil {
+				return "", b, err
+			}
+			r := ToRunes(string(b))
+			return r[i+1 : n+i+1].String(), b[n+i+1:], nil
+		}
+	}
+	return "", b, errors.New("field")
+}
+
+func legacyUnmarshal(p *Packet, b []byte) (err error) {
+	b = b[1:]
+	for _, f := range []*string{&p.Hostname, &p.Login, &p.Domain, &p.Version} {
+		*f, b, err = legacyFindField(b)
+		if err != nil {
+			return
+		}
+	}
+	var s string
+	s, b, err = legacyFindField(b)
+	if err != nil {
+		return
+	}
+	p.Event, _ = strconv.Atoi(s)
+	if b[0] == bodyChar {
+		req := new(Request)
+		req.Path, b, err = legacyFindField(b[1:])
+		if err != nil {
+			return
+		}
+		req.Id, b, err = legacyFindField(b)
+		if err != nil {
+			return
+		}
+		s, b, err = legacyFindField(b)
+		if err != nil {
+			return
+		}
+		req.Method = ToMethod(s)
+		req.ContentType, b, err = legacyFindField(b)
+		if err != nil {
+			return
+		}
+		s, _, err = legacyFindField(b)
+		if err != nil {
+			return
+		}
+		req.Data = []rune(s)
+		p.Request = req
+	}
+	return
+}
+
+//Строки пакета - подстроки одной копии буфера: буфер можно
+//переиспользовать, а выделений памяти не больше четырех -
+//пакет, копия буфера, запрос и данные
+func TestUnmarshalAllocs(t *testing.T) {
+	buf := benchPacket().Marshal()
+	p := new(Packet)
+	if err := p.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	for i := range buf {
+		buf[i] = '0'
+	}
+	if p.Header.Hostname != "COMPUTER-0001" || p.Request.Id != "9b6f0f4c3a3e4c4b8d6f1e2a3b4c5d6e" {
+		t.Errorf("пакет изменился вместе с буфером: %v", p)
+	}
+
+	buf = benchPacket().Marshal()
+	allocs := testing.AllocsPerRun(100, func() {
+		p := new(Packet)
+		if err := p.Unmarshal(buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 4 {
+		t.Errorf("выделений памяти: %v", allocs)
+	}
+}
+
+func BenchmarkUnmarshalLegacy(b *testing.B) {
+	buf := benchPacket().Marshal()
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := new(Packet)
+		if err := legacyUnmarshal(p, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	buf := benchPacket().Marshal()
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := new(Packet)
+		if err := p.Unmarshal(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	p := benchPacket()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = p.AppendMarshal(buf[:0])
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkResponseUnmarshal(b *testing.B) {
+	resp := &Response{
+		Id:          "9b6f0f4c3a3e4c4b8d6f1e2a3b4c5d6e",
+		StatusCode:  StatusCodeOK,
+		ContentType: "json",
+		Data:        ToRunes(`["Декабрь", "Январь", "Февраль"]`),
+	}
+	buf := resp.Marshal()
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := new(Response)
+		if err := r.Unmarshal(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
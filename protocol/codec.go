@@ -0,0 +1,217 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeRaw      = "raw"
+	ContentTypeJSON     = "json"
+	ContentTypeXML      = "xml"
+	ContentTypeMsgpack  = "msgpack"
+	ContentTypeProtobuf = "protobuf"
+)
+
+//Кодек данных запроса и ответа, Name - значение ContentType.
+//Данные бинарных кодеков передаются в Data в base64
+type Codec interface {
+	Name() string
+	Binary() bool
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+var codecs sync.Map
+
+func init() {
+	RegisterCodec(rawCodec{})
+	RegisterCodec(jsonCodec{})
+	RegisterCodec(xmlCodec{})
+	RegisterCodec(msgpackCodec{})
+	RegisterCodec(protobufCodec{})
+}
+
+//Регистрируем кодек, кодек с таким же именем заменяется
+func RegisterCodec(c Codec) {
+	codecs.Store(c.Name(), c)
+}
+
+//Пустой ContentType - raw
+func GetCodec(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = ContentTypeRaw
+	}
+	v, ok := codecs.Load(contentType)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Неизвестный тип данных - %s", contentType))
+	}
+	return v.(Codec), nil
+}
+
+func encode(contentType string, v interface{}) (Runes, error) {
+	c, err := GetCodec(contentType)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.Binary() {
+		return ToRunes(base64.StdEncoding.EncodeToString(b)), nil
+	}
+	return ToRunes(string(b)), nil
+}
+
+func decode(contentType string, data Runes, v interface{}) error {
+	c, err := GetCodec(contentType)
+	if err != nil {
+		return err
+	}
+	b := data.ToByte()
+	if c.Binary() {
+		b, err = base64.StdEncoding.DecodeString(string(b))
+		if err != nil {
+			return err
+		}
+	}
+	return c.Unmarshal(b, v)
+}
+
+//Данные как есть: string, []byte, Runes и указатели на них
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return ContentTypeRaw
+}
+
+func (rawCodec) Binary() bool {
+	return false
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch d := v.(type) {
+	case string:
+		return []byte(d), nil
+	case *string:
+		return []byte(*d), nil
+	case []byte:
+		return d, nil
+	case *[]byte:
+		return *d, nil
+	case Runes:
+		return d.ToByte(), nil
+	case *Runes:
+		return d.ToByte(), nil
+	case []rune:
+		return []byte(string(d)), nil
+	case nil:
+		return nil, nil
+	}
+	return nil, errors.New(fmt.Sprintf("raw: неподдерживаемый тип - %T", v))
+}
+
+func (rawCodec) Unmarshal(b []byte, v interface{}) error {
+	switch d := v.(type) {
+	case *string:
+		*d = string(b)
+	case *[]byte:
+		*d = append((*d)[:0], b...)
+	case *Runes:
+		*d = ToRunes(string(b))
+	case *[]rune:
+		*d = []rune(string(b))
+	default:
+		return errors.New(fmt.Sprintf("raw: неподдерживаемый тип - %T", v))
+	}
+	return nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Binary() bool {
+	return false
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Name() string {
+	return ContentTypeXML
+}
+
+func (xmlCodec) Binary() bool {
+	return false
+}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(b []byte, v interface{}) error {
+	return xml.Unmarshal(b, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return ContentTypeMsgpack
+}
+
+func (msgpackCodec) Binary() bool {
+	return true
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(b []byte, v interface{}) error {
+	return msgpack.Unmarshal(b, v)
+}
+
+//v должен реализовывать proto.Message
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Binary() bool {
+	return true
+}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("protobuf: %T не реализует proto.Message", v))
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(b []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.New(fmt.Sprintf("protobuf: %T не реализует proto.Message", v))
+	}
+	return proto.Unmarshal(b, m)
+}
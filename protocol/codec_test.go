@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type winter struct {
+	Months []string `json:"months" xml:"month" msgpack:"months"`
+}
+
+func TestCodec(t *testing.T) {
+	in := winter{Months: []string{"Декабрь", "Январь", "Февраль"}}
+	for _, contentType := range []string{ContentTypeJSON, ContentTypeXML, ContentTypeMsgpack} {
+		req, err := NewRequest("winter", MethodGet).Encode(contentType, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := new(Packet)
+		err = p.Unmarshal((&Packet{Request: req}).Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := winter{}
+		err = p.Request.Bind(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: %v != %v", contentType, in, out)
+		}
+	}
+}
+
+func TestCodecProtobuf(t *testing.T) {
+	resp, err := NewResponse(nil, 0).Encode(ContentTypeProtobuf, wrapperspb.String("Привет"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := new(Response)
+	err = r.Unmarshal(resp.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(wrapperspb.StringValue)
+	err = r.Bind(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(out, wrapperspb.String("Привет")) {
+		t.Errorf("protobuf: %v", out)
+	}
+	if _, err = NewResponse(nil, 0).Encode(ContentTypeProtobuf, "text"); err == nil {
+		t.Error("protobuf: ожидалась ошибка для типа не proto.Message")
+	}
+}
+
+func TestCodecRaw(t *testing.T) {
+	req, err := NewRequest("hi", MethodNone).Encode("", "Привет, мир!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	err = req.Bind(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "Привет, мир!" {
+		t.Errorf("raw: %s", s)
+	}
+	if _, err = GetCodec("yaml"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного типа")
+	}
+}
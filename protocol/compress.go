@@ -0,0 +1,210 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingSnappy = "snappy"
+)
+
+//Наибольший размер распакованных данных. Сжатый пакет
+//небольшого размера не должен занимать больше памяти
+const MaxDecompressedSize = 1 << 20
+
+var ErrDecompressedSize = errors.New(fmt.Sprintf("Размер распакованных данных больше %d байт", MaxDecompressedSize))
+
+//Алгоритм сжатия данных. Decompress не должен
+//возвращать больше MaxDecompressedSize байт
+type Compressor interface {
+	Name() string
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+var compressors sync.Map
+
+func init() {
+	RegisterCompressor(gzipCompressor{})
+	RegisterCompressor(snappyCompressor{})
+	RegisterCompressor(new(zstdCompressor))
+}
+
+//Регистрируем алгоритм сжатия, алгоритм с таким же именем заменяется
+func RegisterCompressor(c Compressor) {
+	compressors.Store(c.Name(), c)
+}
+
+func GetCompressor(name string) (Compressor, error) {
+	v, ok := compressors.Load(name)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Неизвестный алгоритм сжатия - %s", name))
+	}
+	return v.(Compressor), nil
+}
+
+//Настройка сжатия. Name - алгоритм, пустое значение - сжатие отключено.
+//Данные меньше Threshold байт не сжимаются
+type Compression struct {
+	Name      string
+	Threshold int
+}
+
+func (c Compression) IsNil() bool {
+	return c.Name == ""
+}
+
+//Сжатые данные передаются в Data в base64
+func compress(name string, data Runes) (Runes, error) {
+	c, err := GetCompressor(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.Compress(data.ToByte())
+	if err != nil {
+		return nil, err
+	}
+	return ToRunes(base64.StdEncoding.EncodeToString(b)), nil
+}
+
+func decompress(name string, data Runes) (Runes, error) {
+	c, err := GetCompressor(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(data.String())
+	if err != nil {
+		return nil, err
+	}
+	b, err = c.Decompress(b)
+	if err != nil {
+		return nil, err
+	}
+	//Проверяем и для зарегистрированных пользователем алгоритмов
+	if len(b) > MaxDecompressedSize {
+		return nil, ErrDecompressedSize
+	}
+	return ToRunes(string(b)), nil
+}
+
+//Проверяем, есть ли алгоритм в списке вида "gzip,zstd"
+func accepts(accept, name string) bool {
+	for _, s := range strings.Split(accept, ",") {
+		if strings.TrimSpace(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string {
+	return EncodingGzip
+}
+
+func (gzipCompressor) Compress(b []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err = ioutil.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxDecompressedSize {
+		return nil, ErrDecompressedSize
+	}
+	return b, nil
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string {
+	return EncodingSnappy
+}
+
+func (snappyCompressor) Compress(b []byte) ([]byte, error) {
+	return snappy.Encode(nil, b), nil
+}
+
+func (snappyCompressor) Decompress(b []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(b)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxDecompressedSize {
+		return nil, ErrDecompressedSize
+	}
+	return snappy.Decode(nil, b)
+}
+
+//Encoder и Decoder zstd потокобезопасны для EncodeAll/DecodeAll,
+//создаем их один раз при первом использовании
+type zstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (z *zstdCompressor) init() error {
+	z.once.Do(func() {
+		z.encoder, z.err = zstd.NewWriter(nil)
+		if z.err != nil {
+			return
+		}
+		z.decoder, z.err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+	})
+	return z.err
+}
+
+func (z *zstdCompressor) Name() string {
+	return EncodingZstd
+}
+
+func (z *zstdCompressor) Compress(b []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.encoder.EncodeAll(b, nil), nil
+}
+
+func (z *zstdCompressor) Decompress(b []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	b, err := z.decoder.DecodeAll(b, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrDecompressedSize
+	}
+	return b, err
+}
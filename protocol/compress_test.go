@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := ToRunes(strings.Repeat(`{"month": "Декабрь"},`, 50))
+	for _, name := range []string{EncodingGzip, EncodingZstd, EncodingSnappy} {
+		c := Compression{Name: name, Threshold: 64}
+
+		req, err := NewRequest("winter", MethodGet).SetData("json", data).Compressed(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Encoding != name || len(req.Data) >= len(data) {
+			t.Errorf("%s: данные не сжаты", name)
+		}
+		p := new(Packet)
+		err = p.Unmarshal((&Packet{Request: req}).Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Request.Decompress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Request.Data.String() != data.String() || p.Request.AcceptEncoding != name {
+			t.Errorf("%s: request: %s", name, p.Request.String())
+		}
+
+		resp, err := NewResponse(p.Request, 0).SetData(StatusCodeOK, data).Compressed(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := new(Response)
+		err = r.Unmarshal(resp.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Encoding != name {
+			t.Errorf("%s: ответ не сжат", name)
+		}
+		err = r.Decompress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Data.String() != data.String() {
+			t.Errorf("%s: response: %s", name, r.String())
+		}
+	}
+}
+
+func TestCompressThreshold(t *testing.T) {
+	c := Compression{Name: EncodingGzip, Threshold: 1024}
+	req, err := NewRequest("hi", MethodNone).SetData("json", ToRunes(`{"message": "Hello"}`)).Compressed(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Encoding != "" {
+		t.Error("данные меньше порога не должны сжиматься")
+	}
+	//Клиент не принимает сжатие - ответ не сжимается
+	resp, err := NewResponse(NewRequest("hi", MethodNone), 0).SetData(StatusCodeOK, ToRunes(strings.Repeat("a", 2048))).Compressed(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Encoding != "" {
+		t.Error("клиент не принимает сжатие")
+	}
+}
+
+//Пакет, который распаковывается больше MaxDecompressedSize, отклоняется
+func TestDecompressSize(t *testing.T) {
+	data := ToRunes(strings.Repeat("a", MaxDecompressedSize+1))
+	for _, name := range []string{EncodingGzip, EncodingZstd, EncodingSnappy} {
+		c := Compression{Name: name}
+		req, err := NewRequest("bomb", MethodSet).SetData("text", data).Compressed(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Encoding != name || len(req.Data) >= MaxDecompressedSize/10 {
+			t.Fatalf("%s: данные не сжаты", name)
+		}
+		p := new(Packet)
+		err = p.Unmarshal((&Packet{Request: req}).Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Request.Decompress()
+		if !errors.Is(err, ErrDecompressedSize) {
+			t.Errorf("%s: %v", name, err)
+		}
+		//Ограничение соблюдает сам алгоритм, а не только общая проверка
+		compressor, err := GetCompressor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := compressor.Compress(data.ToByte())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err = compressor.Decompress(b); !errors.Is(err, ErrDecompressedSize) || b != nil {
+			t.Errorf("%s: %d, %v", name, len(b), err)
+		}
+
+		//Данные на границе распаковываются
+		req, err = NewRequest("bomb", MethodSet).SetData("text", data[:MaxDecompressedSize]).Compressed(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = req.Decompress(); err != nil || len(req.Data) != MaxDecompressedSize {
+			t.Errorf("%s: %d, %v", name, len(req.Data), err)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Поле датаграммы. Offset - смещение префикса длины n: в байтах,
+//Length - длина из префикса в символах, Size - размер значения в байтах.
+//У служебных символов ^ # $ префикса нет
+type DissectField struct {
+	Name   string
+	Offset int
+	Length int
+	Size   int
+	Raw    string
+	Value  string
+}
+
+//Разбор датаграммы по полям. Kind - packet или response.
+//При ошибке Fields содержит разобранные до ошибки поля,
+//ErrOffset - смещение ошибки, иначе -1
+type Dissection struct {
+	Kind      string
+	Size      int
+	Fields    []DissectField
+	Err       error
+	ErrOffset int
+}
+
+type dissector struct {
+	reader
+	d      *Dissection
+	hasEnd bool
+}
+
+//Разбираем датаграмму как пакет клиента и как ответ сервера
+//и возвращаем разбор без ошибки, либо тот, что продвинулся дальше.
+//Формат не различает пакет и ответ, поэтому пакет с пустым
+//заголовком (сервер такие не принимает) считаем ответом
+func Dissect(b []byte) *Dissection {
+	packet := DissectPacket(b)
+	if packet.Err == nil && !packet.emptyHeader() {
+		return packet
+	}
+	resp := DissectResponse(b)
+	if resp.Err == nil || (packet.Err != nil && resp.ErrOffset > packet.ErrOffset) {
+		return resp
+	}
+	return packet
+}
+
+func (d *Dissection) emptyHeader() bool {
+	for _, f := range d.Fields {
+		switch f.Name {
+		case "header.hostname", "header.login", "header.domain", "header.version":
+			if f.Raw == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Разбор пакета клиента
+func DissectPacket(b []byte) *Dissection {
+	ds := newDissector("packet", b)
+	if !ds.start() {
+		return ds.d
+	}
+	ok := ds.string("header.hostname") &&
+		ds.string("header.login") &&
+		ds.string("header.domain") &&
+		ds.string("header.version") &&
+		ds.int("header.event", func(i int) string { return Events(i).String() })
+	if ok {
+		if c, more := ds.peek(); more && c == bodyChar {
+			ds.char("body")
+			ok = ds.string("request.path") &&
+				ds.string("request.id") &&
+				ds.int("request.method", func(i int) string { return Methods(i).String() }) &&
+				ds.string("request.content_type") &&
+				ds.string("request.data")
+			if _, more := ds.peek(); ok && more {
+				ok = ds.string("request.encoding") && ds.string("request.accept_encoding")
+			}
+			if _, more := ds.peek(); ok && more {
+				ok = ds.stringMap("request.metadata")
+			}
+		}
+	}
+	ds.end(ok)
+	return ds.d
+}
+
+//Разбор ответа сервера
+func DissectResponse(b []byte) *Dissection {
+	ds := newDissector("response", b)
+	if !ds.start() {
+		return ds.d
+	}
+	ok := ds.string("response.id") &&
+		ds.int("response.status_code", func(i int) string { return toStatusCode(i).String() }) &&
+		ds.int("response.event", func(i int) string { return Events(i).String() }) &&
+		ds.string("response.content_type") &&
+		ds.string("response.data")
+	if _, more := ds.peek(); ok && more {
+		ok = ds.string("response.encoding")
+	}
+	if _, more := ds.peek(); ok && more {
+		ok = ds.string("response.error.message") && ds.stringMap("response.error.details")
+	}
+	if _, more := ds.peek(); ok && more {
+		ok = ds.stringMap("response.metadata")
+	}
+	ds.end(ok)
+	return ds.d
+}
+
+func newDissector(kind string, b []byte) *dissector {
+	return &dissector{
+		reader: reader{b: b},
+		d: &Dissection{
+			Kind:      kind,
+			Size:      len(b),
+			ErrOffset: -1,
+		},
+	}
+}
+
+func (ds *dissector) fail(off int, err error) bool {
+	ds.d.Err = err
+	ds.d.ErrOffset = off
+	return false
+}
+
+func (ds *dissector) char(name string) {
+	ds.d.Fields = append(ds.d.Fields, DissectField{
+		Name:   name,
+		Offset: ds.off,
+		Size:   1,
+		Raw:    string(ds.b[ds.off]),
+	})
+	ds.off++
+}
+
+//Первый символ ^, последний $ отрезаем, как и Unmarshal
+func (ds *dissector) start() bool {
+	if len(ds.b) == 0 || ds.b[0] != startChar {
+		return ds.fail(0, errors.New(fmt.Sprintf("Первый символ должен быть - %v", startChar)))
+	}
+	ds.char("start")
+	if len(ds.b) > 1 && ds.b[len(ds.b)-1] == endChar {
+		ds.b = ds.b[:len(ds.b)-1]
+		ds.hasEnd = true
+	}
+	return true
+}
+
+func (ds *dissector) end(ok bool) {
+	if !ok {
+		return
+	}
+	if ds.off < len(ds.b) {
+		//Unmarshal такие данные пропускает
+		ds.d.Fields = append(ds.d.Fields, DissectField{
+			Name:   "unparsed",
+			Offset: ds.off,
+			Size:   len(ds.b) - ds.off,
+			Raw:    string(ds.b[ds.off:]),
+			Value:  "не разбирается",
+		})
+	}
+	if !ds.hasEnd {
+		ds.fail(ds.d.Size-1, errors.New(fmt.Sprintf("Последний символ должен быть - %v", endChar)))
+		return
+	}
+	ds.off = len(ds.b)
+	ds.b = ds.b[:len(ds.b)+1]
+	ds.char("end")
+}
+
+func (ds *dissector) field(name string) (DissectField, bool) {
+	off := ds.off
+	start, end, n, err := ds.next()
+	if err != nil {
+		return DissectField{}, ds.fail(ds.errOff, err)
+	}
+	return DissectField{
+		Name:   name,
+		Offset: off,
+		Length: n,
+		Size:   end - start,
+		Raw:    string(ds.b[start:end]),
+	}, true
+}
+
+func (ds *dissector) string(name string) bool {
+	f, ok := ds.field(name)
+	if !ok {
+		return false
+	}
+	f.Value = strconv.Quote(f.Raw)
+	ds.d.Fields = append(ds.d.Fields, f)
+	return true
+}
+
+func (ds *dissector) int(name string, value func(i int) string) bool {
+	f, ok := ds.field(name)
+	if !ok {
+		return false
+	}
+	//Как и Unmarshal, пустое значение - 0
+	i := 0
+	for k := 0; k < len(f.Raw); k++ {
+		c := f.Raw[k]
+		if c < '0' || c > '9' {
+			return ds.fail(ds.off-f.Size+k, ErrField)
+		}
+		i = i*10 + int(c-'0')
+	}
+	f.Value = strconv.Itoa(i)
+	if value != nil {
+		f.Value = value(i)
+	}
+	ds.d.Fields = append(ds.d.Fields, f)
+	return true
+}
+
+func (ds *dissector) stringMap(name string) bool {
+	if !ds.int(name+".count", nil) {
+		return false
+	}
+	n, _ := strconv.Atoi(ds.d.Fields[len(ds.d.Fields)-1].Raw)
+	for i := 0; i < n; i++ {
+		key, ok := ds.field(name + ".key")
+		if !ok {
+			return false
+		}
+		key.Value = strconv.Quote(key.Raw)
+		ds.d.Fields = append(ds.d.Fields, key)
+		if !ds.string(name + "[" + key.Raw + "]") {
+			return false
+		}
+	}
+	return true
+}
+
+//Текстовый вывод разбора: смещение, префикс длины, размер, поле, значение
+func (d *Dissection) String() string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "%s: %d байт\n", d.Kind, d.Size)
+	fmt.Fprintf(sb, "%6s  %-7s %5s  %-28s %s\n", "offset", "prefix", "size", "field", "value")
+	for _, f := range d.Fields {
+		prefix := ""
+		if f.Name != "start" && f.Name != "body" && f.Name != "end" && f.Name != "unparsed" {
+			prefix = strconv.Itoa(f.Length) + ":"
+		}
+		value := f.Value
+		if value == "" {
+			value = strconv.Quote(f.Raw)
+		}
+		fmt.Fprintf(sb, "%6d  %-7s %5d  %-28s %s\n", f.Offset, prefix, f.Size, f.Name, value)
+	}
+	if d.Err != nil {
+		fmt.Fprintf(sb, "ошибка на смещении %d: %v\n", d.ErrOffset, d.Err)
+	}
+	return sb.String()
+}
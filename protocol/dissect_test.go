@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func dissectPacket() []byte {
+	p := New("PC-1", "user", "HQ", "1.0.0")
+	p.Request = NewRequest("winter", MethodGet)
+	p.Request.Id = "1"
+	p.Request.Data = ToRunes("Январь")
+	p.Request.SetMetadata("lang", "ru")
+	return p.Marshal()
+}
+
+func findDissectField(d *Dissection, name string) (DissectField, bool) {
+	for _, f := range d.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return DissectField{}, false
+}
+
+func TestDissect(t *testing.T) {
+	b := dissectPacket()
+	d := Dissect(b)
+	if d.Err != nil || d.Kind != "packet" || d.ErrOffset != -1 {
+		t.Fatalf("%s", d)
+	}
+	data, _ := findDissectField(d, "request.data")
+	//Длина в символах, размер в байтах
+	if data.Length != 6 || data.Size != 12 || data.Raw != "Январь" ||
+		!bytes.HasPrefix(b[data.Offset:], []byte("6:Январь")) {
+		t.Errorf("request.data: %+v", data)
+	}
+	method, _ := findDissectField(d, "request.method")
+	if method.Value != MethodGet.String() {
+		t.Errorf("request.method: %+v", method)
+	}
+	if lang, ok := findDissectField(d, "request.metadata[lang]"); !ok || lang.Raw != "ru" {
+		t.Errorf("metadata: %+v", lang)
+	}
+	if last := d.Fields[len(d.Fields)-1]; last.Name != "end" || last.Offset != len(b)-1 {
+		t.Errorf("end: %+v", last)
+	}
+
+	resp := NewResponse(&Request{Id: "1"}, 0).SetError(NewError(StatusCodeNotFound, "нет"))
+	d = Dissect(resp.Marshal())
+	if d.Err != nil || d.Kind != "response" {
+		t.Fatalf("%s", d)
+	}
+	if code, _ := findDissectField(d, "response.status_code"); code.Value != StatusCodeNotFound.String() {
+		t.Errorf("status_code: %+v", code)
+	}
+	//Ответ на подключение разбирается и как пакет с пустым заголовком
+	d = Dissect(NewResponse(nil, int(EventConnected)).Marshal())
+	if d.Err != nil || d.Kind != "response" {
+		t.Errorf("%s", d)
+	}
+}
+
+func TestDissectMalformed(t *testing.T) {
+	b := dissectPacket()
+	path := bytes.Index(b, []byte("6:winter"))
+
+	tests := []struct {
+		name   string
+		b      []byte
+		offset int
+		last   string
+	}{
+		{"prefix", replaceByte(b, path, 'x'), path, "body"},
+		//Длина больше чем есть данных: поле упирается в $
+		{"length", bytes.Replace(b, []byte("2:ru$"), []byte("5:ru$"), 1), len(b) - 1, "request.metadata.key"},
+		{"int", bytes.Replace(b, []byte("1:0#"), []byte("1:x#"), 1), bytes.Index(b, []byte("1:0#")) + 2, "header.version"},
+		{"end", b[:len(b)-1], len(b) - 2, "request.metadata[lang]"},
+		{"start", b[1:], 0, ""},
+	}
+	for _, tt := range tests {
+		d := DissectPacket(tt.b)
+		if d.Err == nil || d.ErrOffset != tt.offset {
+			t.Errorf("%s: offset %d, ожидалось %d\n%s", tt.name, d.ErrOffset, tt.offset, d)
+			continue
+		}
+		last := ""
+		if len(d.Fields) > 0 {
+			last = d.Fields[len(d.Fields)-1].Name
+		}
+		if last != tt.last {
+			t.Errorf("%s: последнее поле %s, ожидалось %s", tt.name, last, tt.last)
+		}
+		//Unmarshal тоже должен вернуть ошибку
+		if err := new(Packet).Unmarshal(tt.b); err == nil {
+			t.Errorf("%s: Unmarshal без ошибки", tt.name)
+		}
+	}
+}
+
+func replaceByte(b []byte, i int, c byte) []byte {
+	b = append([]byte(nil), b...)
+	b[i] = c
+	return b
+}
@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Структурированная ошибка ответа. Сравнение через errors.Is
+//выполняется по коду, например errors.Is(err, protocol.ErrNotFound)
+type Error struct {
+	Code    StatusCode
+	Message string
+	Details map[string]string
+}
+
+var (
+	ErrBadRequest       = &Error{Code: StatusCodeBadRequest}
+	ErrUnauthorized     = &Error{Code: StatusCodeUnauthorized}
+	ErrForbidden        = &Error{Code: StatusCodeForbidden}
+	ErrNotFound         = &Error{Code: StatusCodeNotFound}
+	ErrMethodNotAllowed = &Error{Code: StatusCodeMethodNotAllowed}
+	ErrTimeout          = &Error{Code: StatusCodeTimeout}
+	ErrConflict         = &Error{Code: StatusCodeConflict}
+	ErrRateLimited      = &Error{Code: StatusCodeRateLimited}
+	ErrInternal         = &Error{Code: StatusCodeInternal}
+	ErrNotImplemented   = &Error{Code: StatusCodeNotImplemented}
+	ErrUnavailable      = &Error{Code: StatusCodeUnavailable}
+)
+
+func NewError(code StatusCode, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func Errorf(code StatusCode, format string, a ...interface{}) *Error {
+	return NewError(code, fmt.Sprintf(format, a...))
+}
+
+func (e *Error) WithDetail(key, value string) *Error {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *Error) Error() string {
+	if len(e.Details) == 0 {
+		return fmt.Sprintf("%s: %s", e.Code.String(), e.Message)
+	}
+	keys := make([]string, 0, len(e.Details))
+	for key := range e.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	details := make([]string, len(keys))
+	for i, key := range keys {
+		details[i] = fmt.Sprintf("%s=%s", key, e.Details[key])
+	}
+	return fmt.Sprintf("%s: %s [%s]", e.Code.String(), e.Message, strings.Join(details, ", "))
+}
+
+//Ошибки равны по коду статуса. Типизированный nil
+//в target ни с одной ошибкой не совпадает
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t != nil && e != nil && t.Code == e.Code
+}
+
+//Реализует server.StatusCoder
+func (e *Error) StatusCode() StatusCode {
+	return e.Code
+}
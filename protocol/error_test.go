@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestResponseError(t *testing.T) {
+	e := NewError(StatusCodeNotFound, "Маршрут [winter] не найден").WithDetail("path", "winter")
+	resp := NewResponse(&Request{Id: "1"}, 0).SetError(e)
+
+	r := new(Response)
+	err := r.Unmarshal(resp.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != StatusCodeNotFound {
+		t.Errorf("status_code: %s", r.StatusCode.String())
+	}
+	//Старые клиенты видят сообщение в Data
+	if r.Data.String() != e.Message {
+		t.Errorf("data: %s", r.Data.String())
+	}
+
+	err = fmt.Errorf("call: %w", r.Err())
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrBadRequest) {
+		t.Errorf("errors.Is: %v", err)
+	}
+	if errors.Is(err, (*Error)(nil)) {
+		t.Errorf("errors.Is(nil): %v", err)
+	}
+	var target *Error
+	if !errors.As(err, &target) {
+		t.Fatalf("errors.As: %v", err)
+	}
+	if target.Message != e.Message || target.Details["path"] != "winter" {
+		t.Errorf("error: %v", target)
+	}
+}
+
+func TestResponseErrorLegacy(t *testing.T) {
+	//Ответ без структурированной ошибки
+	resp := NewResponse(nil, 0).SetData(StatusCodeError, ToRunes("ошибка"))
+	r := new(Response)
+	err := r.Unmarshal(resp.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Error != nil {
+		t.Errorf("error: %v", r.Error)
+	}
+	var target *Error
+	if !errors.As(r.Err(), &target) || target.Code != StatusCodeError || target.Message != "ошибка" {
+		t.Errorf("err: %v", r.Err())
+	}
+	if NewResponse(nil, 0).SetData(StatusCodeOK, nil).Err() != nil {
+		t.Error("StatusCodeOK не ошибка")
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	for code := range statusCodes {
+		resp := &Response{StatusCode: code}
+		r := new(Response)
+		err := r.Unmarshal(resp.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.StatusCode != code {
+			t.Errorf("%s != %s", r.StatusCode.String(), code.String())
+		}
+	}
+	if ToStatusCode("999") != StatusCodeError {
+		t.Error("неизвестный код должен быть StatusCodeError")
+	}
+}
@@ -42,6 +42,9 @@ func (e Events) String() string {
 	case EventDisconnect:
 		s = "EventDisconnect"
 		break
+	case EventCheckConnection:
+		s = "EventCheckConnection"
+		break
 	}
 	return fmt.Sprintf("%s(%d)", s, e)
 }
@@ -0,0 +1,175 @@
+package protocol
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+var ErrField = errors.New("Не удалось определить поле. Формат должен быть вида - n:word")
+
+//Длина поля n в формате n:word указывается в символах (рунах)
+
+//Добавляем поле n:word
+func appendField(b []byte, s string) []byte {
+	b = strconv.AppendInt(b, int64(utf8.RuneCountInString(s)), 10)
+	b = append(b, ':')
+	return append(b, s...)
+}
+
+//Добавляем поле из рун n:word
+func appendRunes(b []byte, r Runes) []byte {
+	b = strconv.AppendInt(b, int64(len(r)), 10)
+	b = append(b, ':')
+	var buf [utf8.UTFMax]byte
+	for _, c := range r {
+		n := utf8.EncodeRune(buf[:], c)
+		b = append(b, buf[:n]...)
+	}
+	return b
+}
+
+//Добавляем числовое поле n:number
+func appendInt(b []byte, i int) []byte {
+	var num [20]byte
+	v := strconv.AppendInt(num[:0], int64(i), 10)
+	b = strconv.AppendInt(b, int64(len(v)), 10)
+	b = append(b, ':')
+	return append(b, v...)
+}
+
+//Добавляем словарь: количество пар n:count и пары полей n:key n:value,
+//ключи по возрастанию
+func appendMap(b []byte, m map[string]string) []byte {
+	b = appendInt(b, len(m))
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b = appendField(b, key)
+		b = appendField(b, m[key])
+	}
+	return b
+}
+
+//Последовательный разбор полей за один проход по буферу
+type reader struct {
+	b   []byte
+	off int
+	//Смещение на котором разбор завершился ошибкой
+	errOff int
+	//Копия буфера, создается при разборе первой строки.
+	//Строковые поля - ее подстроки, поэтому на все строки
+	//пакета приходится одно выделение памяти
+	s string
+}
+
+func (r *reader) peek() (byte, bool) {
+	if r.off >= len(r.b) {
+		return 0, false
+	}
+	return r.b[r.off], true
+}
+
+func (r *reader) skip() {
+	r.off++
+}
+
+//Возвращаем границы значения очередного поля и количество рун в нем
+func (r *reader) next() (start, end, n int, err error) {
+	i := r.off
+	for ; i < len(r.b) && r.b[i] != ':'; i++ {
+		c := r.b[i]
+		if c < '0' || c > '9' {
+			r.errOff = i
+			return 0, 0, 0, ErrField
+		}
+		n = n*10 + int(c-'0')
+	}
+	if i == r.off || i == len(r.b) {
+		r.errOff = i
+		return 0, 0, 0, ErrField
+	}
+	start = i + 1
+	end = start
+	for k := 0; k < n; k++ {
+		if end >= len(r.b) {
+			r.errOff = end
+			return 0, 0, 0, ErrField
+		}
+		if r.b[end] < utf8.RuneSelf {
+			end++
+			continue
+		}
+		_, size := utf8.DecodeRune(r.b[end:])
+		end += size
+	}
+	r.off = end
+	return start, end, n, nil
+}
+
+func (r *reader) string() (string, error) {
+	start, end, _, err := r.next()
+	if err != nil {
+		return "", err
+	}
+	if r.s == "" {
+		r.s = string(r.b)
+	}
+	return r.s[start:end], nil
+}
+
+func (r *reader) int() (int, error) {
+	start, end, _, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	i := 0
+	for k, c := range r.b[start:end] {
+		if c < '0' || c > '9' {
+			r.errOff = start + k
+			return 0, ErrField
+		}
+		i = i*10 + int(c-'0')
+	}
+	return i, nil
+}
+
+func (r *reader) runes() (Runes, error) {
+	start, end, n, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	runes := make(Runes, 0, n)
+	for b := r.b[start:end]; len(b) > 0; {
+		c, size := utf8.DecodeRune(b)
+		runes = append(runes, c)
+		b = b[size:]
+	}
+	return runes, nil
+}
+
+func (r *reader) stringMap() (map[string]string, error) {
+	n, err := r.int()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		key, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		m[key], err = r.string()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -1,6 +1,11 @@
 package protocol
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type Methods int
 
@@ -8,31 +13,67 @@ const (
 	MethodNone Methods = iota
 	MethodGet
 	MethodSet
+	MethodCreate
+	MethodUpdate
+	MethodDelete
+	MethodCall
+	//Одностороннее уведомление, сервер не отправляет ответ,
+	//клиент не ждет ответа
+	MethodNotify
 )
 
+var methods = map[Methods]string{
+	MethodNone:   "MethodNone",
+	MethodGet:    "MethodGet",
+	MethodSet:    "MethodSet",
+	MethodCreate: "MethodCreate",
+	MethodUpdate: "MethodUpdate",
+	MethodDelete: "MethodDelete",
+	MethodCall:   "MethodCall",
+	MethodNotify: "MethodNotify",
+}
+
+//Неизвестный метод сохраняет свое значение, чтобы сервер
+//мог ответить клиенту StatusCodeMethodNotAllowed
 func ToMethod(s string) Methods {
-	var method Methods
-	switch s {
-	case "1":
-		method = MethodGet
-		break
-	case "2":
-		method = MethodSet
-	default:
-		method = MethodNone
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return MethodNone
+	}
+	return Methods(i)
+}
+
+//Разбираем метод по номеру или имени: "1", "get", "MethodGet"
+func ParseMethod(s string) (Methods, error) {
+	if i, err := strconv.Atoi(s); err == nil {
+		m := Methods(i)
+		if !m.IsValid() {
+			return m, errors.New(fmt.Sprintf("Неизвестный метод - %s", s))
+		}
+		return m, nil
 	}
-	return method
+	for m, name := range methods {
+		if strings.EqualFold(s, name) || strings.EqualFold("Method"+s, name) {
+			return m, nil
+		}
+	}
+	return MethodNone, errors.New(fmt.Sprintf("Неизвестный метод - %s", s))
+}
+
+func (m Methods) IsValid() bool {
+	_, ok := methods[m]
+	return ok
+}
+
+//Метод без ответа
+func (m Methods) IsOneWay() bool {
+	return m == MethodNotify
 }
 
 func (m Methods) String() string {
-	s := "MethodNone"
-	switch m {
-	case MethodGet:
-		s = "MethodGet"
-		break
-	case MethodSet:
-		s = "MethodSet"
-		break
+	s, ok := methods[m]
+	if !ok {
+		s = "MethodUnknown"
 	}
 	return fmt.Sprintf("%s(%d)", s, m)
 }
@@ -0,0 +1,51 @@
+package protocol
+
+import "testing"
+
+func TestMethods(t *testing.T) {
+	for m := range methods {
+		p := new(Packet)
+		err := p.Unmarshal((&Packet{Request: NewRequest("path", m)}).Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Request.Method != m {
+			t.Errorf("%s != %s", p.Request.Method.String(), m.String())
+		}
+		parsed, err := ParseMethod(methods[m])
+		if err != nil || parsed != m {
+			t.Errorf("ParseMethod(%s): %s, %v", m.String(), parsed.String(), err)
+		}
+	}
+}
+
+func TestMethodUnknown(t *testing.T) {
+	//Многозначный неизвестный метод не должен превращаться в MethodNone
+	p := new(Packet)
+	err := p.Unmarshal((&Packet{Request: NewRequest("path", Methods(42))}).Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Request.Method != 42 || p.Request.Method.IsValid() {
+		t.Errorf("method: %s", p.Request.Method.String())
+	}
+	for _, s := range []string{"42", "patch", ""} {
+		if _, err = ParseMethod(s); err == nil {
+			t.Errorf("ParseMethod(%q): ожидалась ошибка", s)
+		}
+	}
+	for _, s := range []string{"get", "Notify", "MethodDelete", "6"} {
+		if _, err = ParseMethod(s); err != nil {
+			t.Errorf("ParseMethod(%q): %v", s, err)
+		}
+	}
+}
+
+func TestNotifyResponse(t *testing.T) {
+	if !NewResponse(NewRequest("event", MethodNotify), 0).IsOneWay() {
+		t.Error("ответ на MethodNotify не отправляется")
+	}
+	if NewResponse(NewRequest("event", MethodCall), 0).IsOneWay() {
+		t.Error("ответ на MethodCall отправляется")
+	}
+}
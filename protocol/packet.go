@@ -1,10 +1,8 @@
 package protocol
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"strconv"
 	"sync"
 )
 
@@ -33,6 +31,9 @@ const (
 	1:0-method
 	4:type
 	125:data
+	4:encoding - необязательно
+	9:accept-encoding - необязательно
+	1:2 4:lang 2:ru 5:trace 3:abc - metadata, необязательно
 	$-endChar
 */
 
@@ -54,106 +55,146 @@ func (p *Packet) SetEvent(event int) {
 	p.Unlock()
 }
 
+//Меняем событие на event, только если текущее событие old
+func (p *Packet) CompareAndSetEvent(old, event int) bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.Event != old {
+		return false
+	}
+	p.Event = event
+	return true
+}
+
 func (p *Packet) GetEvent() int {
 	p.Lock()
 	defer p.Unlock()
 	return p.Event
 }
 
-func (p *Packet) Marshal() (b []byte) {
-	buf := bytes.NewBuffer(b)
-	buf.Write([]byte(string(startChar)))
+func (p *Packet) Marshal() []byte {
+	return p.AppendMarshal(make([]byte, 0, 128))
+}
+
+//Добавляем пакет к b, позволяет переиспользовать буфер
+func (p *Packet) AppendMarshal(b []byte) []byte {
+	b = append(b, startChar)
 	//header
 	header := p.Header
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(header.Hostname), header.Hostname)))
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(header.Login), header.Login)))
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(header.Domain), header.Domain)))
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(header.Version), header.Version)))
-	buf.Write([]byte(fmt.Sprintf("1:%d", header.Event)))
+	b = appendField(b, header.Hostname)
+	b = appendField(b, header.Login)
+	b = appendField(b, header.Domain)
+	b = appendField(b, header.Version)
+	b = appendInt(b, header.Event)
 	//bodyChar
 	if p.Request != nil {
 		req := p.Request
-		buf.Write([]byte(string(bodyChar)))
-		buf.Write([]byte(fmt.Sprintf("%d:%s", len(req.Path), req.Path)))
-		buf.Write([]byte(fmt.Sprintf("%d:%s", len(req.Id), req.Id)))
-		buf.Write([]byte(fmt.Sprintf("1:%d", req.Method)))
-		buf.Write([]byte(fmt.Sprintf("%d:%s", len(req.ContentType), req.ContentType)))
-		buf.Write([]byte(fmt.Sprintf("%d:%s", len(req.Data), req.Data)))
+		b = append(b, bodyChar)
+		b = appendField(b, req.Path)
+		b = appendField(b, req.Id)
+		b = appendInt(b, int(req.Method))
+		b = appendField(b, req.ContentType)
+		b = appendRunes(b, req.Data)
+		//Необязательные поля, старые версии их не пишут и не читают
+		if req.Encoding != "" || req.AcceptEncoding != "" || len(req.Metadata) > 0 {
+			b = appendField(b, req.Encoding)
+			b = appendField(b, req.AcceptEncoding)
+		}
+		if len(req.Metadata) > 0 {
+			b = appendMap(b, req.Metadata)
+		}
 	}
-	buf.Write([]byte(string(endChar)))
-
-	return buf.Bytes()
-
+	return append(b, endChar)
 }
 
-func (p *Packet) Unmarshal(b []byte) error {
+//Разбор пакета за один проход. Буфер b после разбора
+//не используется и может быть переиспользован
+func (p *Packet) Unmarshal(b []byte) (err error) {
 
-	if b[0] != startChar {
+	if len(b) == 0 || b[0] != startChar {
 		return errors.New(fmt.Sprintf("Первый символ должен быть - %v", startChar))
 	}
 	if b[len(b)-1] != endChar {
 		return errors.New(fmt.Sprintf("Последний символ должен быть - %v", endChar))
 	}
-	var err error
+	r := reader{b: b[:len(b)-1], off: 1}
+
 	//1. hostname
-	p.Header.Hostname, b, err = findField(b[1:])
+	p.Header.Hostname, err = r.string()
 	if err != nil {
-		return err
+		return
 	}
 	//2. login
-	p.Header.Login, b, err = findField(b)
+	p.Header.Login, err = r.string()
 	if err != nil {
-		return err
+		return
 	}
 	//3. domain
-	p.Header.Domain, b, err = findField(b)
+	p.Header.Domain, err = r.string()
 	if err != nil {
-		return err
+		return
 	}
 	//4. version client
-	p.Header.Version, b, err = findField(b)
+	p.Header.Version, err = r.string()
 	if err != nil {
-		return err
+		return
 	}
 	//5. event
-	event, b, err := findField(b)
+	p.Header.Event, err = r.int()
 	if err != nil {
-		return err
+		return
 	}
-	p.Header.Event, _ = strconv.Atoi(event)
 
 	//body
-	if b[0] == bodyChar {
+	if c, ok := r.peek(); ok && c == bodyChar {
+		r.skip()
 
 		req := new(Request)
 
 		//1. route
-		req.Path, b, err = findField(b[1:])
+		req.Path, err = r.string()
 		if err != nil {
-			return err
+			return
 		}
 		//2. Id
-		req.Id, b, err = findField(b)
+		req.Id, err = r.string()
 		if err != nil {
-			return err
+			return
 		}
 		//3. method
-		method, b, err := findField(b)
+		method, err := r.int()
 		if err != nil {
 			return err
 		}
-		req.Method = ToMethod(method)
+		req.Method = Methods(method)
 		//4. type
-		req.ContentType, b, err = findField(b)
+		req.ContentType, err = r.string()
 		if err != nil {
 			return err
 		}
-		//4. data
-		data, b, err := findField(b)
+		//5. data
+		req.Data, err = r.runes()
 		if err != nil {
 			return err
 		}
-		req.Data = []rune(data)
+		//6. encoding, 7. accept-encoding, необязательные поля
+		if _, ok := r.peek(); ok {
+			req.Encoding, err = r.string()
+			if err != nil {
+				return err
+			}
+			req.AcceptEncoding, err = r.string()
+			if err != nil {
+				return err
+			}
+		}
+		//8. metadata, необязательное поле
+		if _, ok := r.peek(); ok {
+			req.Metadata, err = r.stringMap()
+			if err != nil {
+				return err
+			}
+		}
 
 		p.Request = req
 	}
@@ -161,20 +202,6 @@ func (p *Packet) Unmarshal(b []byte) error {
 	return nil
 }
 
-func findField(b []byte) (string, []byte, error) {
-	for i, value := range b {
-		if value == ':' {
-			n, err := strconv.Atoi(string(b[:i]))
-			if err != nil {
-				return "", b, err
-			}
-			r := ToRunes(string(b))
-			return r[i+1 : n+i+1].String(), b[n+i+1:], nil
-		}
-	}
-	return "", b, errors.New("Не удалось определить поле. Формат должен быть вида - n:word")
-}
-
 func (p *Packet) String() string {
 	req := "null"
 	if p.Request != nil {
@@ -13,14 +13,14 @@ func TestUnmarshal(t *testing.T) {
 			Login:    "user",
 			Domain:   "HQ",
 			Version:  "3.3.6",
-			Event:    EventDisconnect,
+			Event:    int(EventDisconnect),
 		},
 		Request: &Request{
 			Path:        "example",
 			Id:          "123456",
 			Method:      MethodSet,
 			ContentType: "json",
-			Data:        []byte(`{"message": "Hello, world!"}`),
+			Data:        ToRunes(`{"message": "Hello, world!"}`),
 		},
 	}
 	b := p1.Marshal()
@@ -38,10 +38,73 @@ func TestUnmarshal(t *testing.T) {
 			Message string `json:"message"`
 		}
 		data := Data{}
-		err = json.Unmarshal(p.Request.Data, &data)
+		err = json.Unmarshal(p.Request.Data.ToByte(), &data)
 		if err != nil {
 			t.Error(err)
 		}
 		fmt.Println(data)
 	}
 }
+
+func TestUnmarshalUnicode(t *testing.T) {
+	p1 := Packet{
+		Header: Header{
+			Hostname: "Компьютер",
+			Login:    "пользователь",
+			Domain:   "HQ",
+			Version:  "1.0.0",
+			Event:    int(EventConnected),
+		},
+		Request: &Request{
+			Path:        "зима",
+			Id:          "1",
+			Method:      MethodGet,
+			ContentType: "json",
+			Data:        ToRunes(`["Декабрь", "Январь", "Февраль"]`),
+		},
+	}
+	p := new(Packet)
+	err := p.Unmarshal(p1.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header != p1.Header {
+		t.Errorf("header: %s != %s", p.Header.String(), p1.Header.String())
+	}
+	if p.Request.String() != p1.Request.String() {
+		t.Errorf("request: %s != %s", p.Request.String(), p1.Request.String())
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	b := (&Packet{Header: Header{Hostname: "Computer"}}).Marshal()
+	for _, data := range [][]byte{nil, b[:5], []byte("^9:Computer$"), []byte("^x:1$")} {
+		if err := new(Packet).Unmarshal(data); err == nil {
+			t.Errorf("%q: ожидалась ошибка", data)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	req := NewRequest("winter", MethodGet).
+		SetMetadata("trace", "4bf92f3577b34da6").
+		SetMetadata("locale", "ru-RU")
+	p := new(Packet)
+	err := p.Unmarshal((&Packet{Request: req}).Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Request.GetMetadata("trace") != "4bf92f3577b34da6" || p.Request.GetMetadata("locale") != "ru-RU" {
+		t.Errorf("request metadata: %v", p.Request.Metadata)
+	}
+
+	resp := NewResponse(req, 0).SetData(StatusCodeOK, ToRunes("ok")).SetMetadata("server", "srv-1")
+	r := new(Response)
+	err = r.Unmarshal(resp.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GetMetadata("server") != "srv-1" || r.Error != nil || r.Err() != nil {
+		t.Errorf("response: %s", r.String())
+	}
+}
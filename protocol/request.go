@@ -10,10 +10,20 @@ type Request struct {
 	Id          string
 	ContentType string
 	Data        Runes
+	//Алгоритм которым сжаты Data, пусто - без сжатия
+	Encoding string
+	//Алгоритмы сжатия которые клиент принимает в ответе
+	AcceptEncoding string
+	//Метаданные запроса: идентификаторы трассировки, токены, локаль и т.д.
+	Metadata map[string]string
 }
 
 type IRequest interface {
 	SetData(contentType string, data Runes) *Request
+	SetMetadata(key, value string) *Request
+	GetMetadata(key string) string
+	Encode(contentType string, v interface{}) (*Request, error)
+	Bind(v interface{}) error
 }
 
 func NewRequest(path string, method Methods) *Request {
@@ -29,10 +39,76 @@ func (r *Request) SetData(contentType string, data Runes) *Request {
 	return r
 }
 
+func (r *Request) SetMetadata(key, value string) *Request {
+	if r.Metadata == nil {
+		r.Metadata = map[string]string{}
+	}
+	r.Metadata[key] = value
+	return r
+}
+
+func (r *Request) GetMetadata(key string) string {
+	return r.Metadata[key]
+}
+
+//Кодируем v кодеком contentType и устанавливаем как данные запроса
+func (r *Request) Encode(contentType string, v interface{}) (*Request, error) {
+	data, err := encode(contentType, v)
+	if err != nil {
+		return nil, err
+	}
+	return r.SetData(contentType, data), nil
+}
+
+//Декодируем данные запроса в v кодеком по ContentType
+func (r *Request) Bind(v interface{}) error {
+	return decode(r.ContentType, r.Data, v)
+}
+
+//Возвращаем копию запроса со сжатыми данными. Если данные меньше
+//порога или сжатие не уменьшило их размер, то данные не сжимаются
+func (r *Request) Compressed(c Compression) (*Request, error) {
+	if c.IsNil() {
+		return r, nil
+	}
+	req := *r
+	req.AcceptEncoding = c.Name
+	if r.Encoding != "" {
+		return &req, nil
+	}
+	size := len(r.Data.ToByte())
+	if size < c.Threshold {
+		return &req, nil
+	}
+	data, err := compress(c.Name, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < size {
+		req.Data = data
+		req.Encoding = c.Name
+	}
+	return &req, nil
+}
+
+//Распаковываем сжатые данные
+func (r *Request) Decompress() error {
+	if r.Encoding == "" {
+		return nil
+	}
+	data, err := decompress(r.Encoding, r.Data)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+	r.Encoding = ""
+	return nil
+}
+
 func (r *Request) String() string {
 	data := "null"
 	if r.Data != nil {
 		data = fmt.Sprintf("%s", r.Data)
 	}
-	return fmt.Sprintf("Id: %s, path: %s, method: %s, type: %s, data: %s", r.Id, r.Path, r.Method.String(), r.ContentType, data)
+	return fmt.Sprintf("Id: %s, path: %s, method: %s, type: %s, encoding: %s, metadata: %v, data: %s", r.Id, r.Path, r.Method.String(), r.ContentType, r.Encoding, r.Metadata, data)
 }
@@ -1,10 +1,8 @@
 package protocol
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"strconv"
 )
 
 type Response struct {
@@ -13,6 +11,16 @@ type Response struct {
 	Event       int
 	ContentType string
 	Data        Runes
+	//Алгоритм которым сжаты Data, пусто - без сжатия
+	Encoding string
+	//Описание ошибки, если StatusCode не StatusCodeOK
+	Error *Error
+	//Метаданные ответа
+	Metadata map[string]string
+	//Алгоритмы сжатия которые принимает клиент, из запроса
+	accept string
+	//Ответ на одностороннее уведомление не отправляется
+	oneWay bool
 }
 
 type Runes []rune
@@ -33,6 +41,12 @@ type IResponse interface {
 	GetID() string
 	SetData(code StatusCode, data Runes) *Response
 	SetContentType(s string) *Response
+	SetError(err error) *Response
+	SetMetadata(key, value string) *Response
+	GetMetadata(key string) string
+	Encode(contentType string, v interface{}) (*Response, error)
+	Compressed(c Compression) (*Response, error)
+	IsOneWay() bool
 	Marshal() []byte
 	Unmarshal(b []byte) error
 }
@@ -44,6 +58,8 @@ func NewResponse(req *Request, event int) IResponse {
 	if req != nil {
 		resp.Id = req.Id
 		resp.ContentType = req.ContentType
+		resp.accept = req.AcceptEncoding
+		resp.oneWay = req.Method.IsOneWay()
 	}
 	return resp
 }
@@ -52,6 +68,11 @@ func (r *Response) GetID() string {
 	return r.Id
 }
 
+//Ответ на MethodNotify, отправлять его не нужно
+func (r *Response) IsOneWay() bool {
+	return r.oneWay
+}
+
 func (r *Response) SetContentType(s string) *Response {
 	r.ContentType = s
 	return r
@@ -63,56 +84,193 @@ func (r *Response) SetData(code StatusCode, data Runes) *Response {
 	return r
 }
 
-func (r *Response) Marshal() (b []byte) {
-	buf := bytes.NewBuffer(b)
-	buf.Write([]byte(string(startChar)))
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(r.Id), r.Id)))
-	buf.Write([]byte(fmt.Sprintf("1:%d", r.StatusCode)))
-	buf.Write([]byte(fmt.Sprintf("1:%d", r.Event)))
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(r.ContentType), r.ContentType)))
-	buf.Write([]byte(fmt.Sprintf("%d:%s", len(r.Data), r.Data)))
-	buf.Write([]byte(string(endChar)))
+func (r *Response) SetMetadata(key, value string) *Response {
+	if r.Metadata == nil {
+		r.Metadata = map[string]string{}
+	}
+	r.Metadata[key] = value
+	return r
+}
+
+func (r *Response) GetMetadata(key string) string {
+	return r.Metadata[key]
+}
+
+//Устанавливаем ошибку. Если err не *Error, то код - StatusCodeError.
+//Сообщение дублируется в Data для клиентов прежних версий
+func (r *Response) SetError(err error) *Response {
+	e, ok := err.(*Error)
+	if !ok {
+		e = NewError(StatusCodeError, err.Error())
+	}
+	r.StatusCode = e.Code
+	r.Error = e
+	r.ContentType = ""
+	r.Data = ToRunes(e.Message)
+	r.Encoding = ""
+	return r
+}
+
+//Ошибка ответа, nil - если StatusCode равен StatusCodeOK
+func (r *Response) Err() error {
+	if r.StatusCode == StatusCodeOK {
+		return nil
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+	return NewError(r.StatusCode, r.Data.String())
+}
+
+//Кодируем v кодеком contentType и устанавливаем как данные ответа
+func (r *Response) Encode(contentType string, v interface{}) (*Response, error) {
+	data, err := encode(contentType, v)
+	if err != nil {
+		return nil, err
+	}
+	r.ContentType = contentType
+	r.Data = data
+	return r, nil
+}
+
+//Декодируем данные ответа в v кодеком по ContentType
+func (r *Response) Bind(v interface{}) error {
+	return decode(r.ContentType, r.Data, v)
+}
+
+//Возвращаем копию ответа со сжатыми данными, если клиент
+//принимает алгоритм c.Name и данные не меньше порога
+func (r *Response) Compressed(c Compression) (*Response, error) {
+	if c.IsNil() || r.Encoding != "" || !accepts(r.accept, c.Name) {
+		return r, nil
+	}
+	size := len(r.Data.ToByte())
+	if size < c.Threshold {
+		return r, nil
+	}
+	data, err := compress(c.Name, r.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) >= size {
+		return r, nil
+	}
+	resp := *r
+	resp.Data = data
+	resp.Encoding = c.Name
+	return &resp, nil
+}
+
+//Распаковываем сжатые данные
+func (r *Response) Decompress() error {
+	if r.Encoding == "" {
+		return nil
+	}
+	data, err := decompress(r.Encoding, r.Data)
+	if err != nil {
+		return err
+	}
+	r.Data = data
+	r.Encoding = ""
+	return nil
+}
+
+func (r *Response) Marshal() []byte {
+	return r.AppendMarshal(make([]byte, 0, 64))
+}
 
-	return buf.Bytes()
+//Добавляем ответ к b, позволяет переиспользовать буфер
+func (r *Response) AppendMarshal(b []byte) []byte {
+	b = append(b, startChar)
+	b = appendField(b, r.Id)
+	b = appendInt(b, int(r.StatusCode))
+	b = appendInt(b, r.Event)
+	b = appendField(b, r.ContentType)
+	b = appendRunes(b, r.Data)
+	//Необязательные поля, старые версии их не пишут и не читают
+	if r.Encoding != "" || r.Error != nil || len(r.Metadata) > 0 {
+		b = appendField(b, r.Encoding)
+	}
+	if r.Error != nil || len(r.Metadata) > 0 {
+		e := r.Error
+		if e == nil {
+			e = &Error{}
+		}
+		b = appendField(b, e.Message)
+		b = appendMap(b, e.Details)
+	}
+	if len(r.Metadata) > 0 {
+		b = appendMap(b, r.Metadata)
+	}
+	return append(b, endChar)
 }
 
 func (r *Response) Unmarshal(b []byte) (err error) {
 
-	if b[0] != startChar {
+	if len(b) == 0 || b[0] != startChar {
 		return errors.New(fmt.Sprintf("Первый символ должен быть - %v", startChar))
 	}
 	if b[len(b)-1] != endChar {
 		return errors.New(fmt.Sprintf("Последний символ должен быть - %v", endChar))
 	}
+	rd := reader{b: b[:len(b)-1], off: 1}
 
 	//1. Id
-	r.Id, b, err = findField(b[1:])
+	r.Id, err = rd.string()
 	if err != nil {
 		return
 	}
 	//2. status-code
-	code, b, err := findField(b)
+	code, err := rd.int()
 	if err != nil {
 		return
 	}
-	r.StatusCode = ToStatusCode(code)
+	r.StatusCode = toStatusCode(code)
 	//3. event
-	event, b, err := findField(b)
+	r.Event, err = rd.int()
 	if err != nil {
 		return
 	}
-	r.Event, _ = strconv.Atoi(event)
 	//4. content-type
-	r.ContentType, b, err = findField(b)
+	r.ContentType, err = rd.string()
 	if err != nil {
 		return
 	}
 	//5. data
-	data, b, err := findField(b)
+	r.Data, err = rd.runes()
 	if err != nil {
 		return
 	}
-	r.Data = []rune(data)
+	//6. encoding, необязательное поле
+	if _, ok := rd.peek(); ok {
+		r.Encoding, err = rd.string()
+		if err != nil {
+			return
+		}
+	}
+	//7. error message, 8. error details, необязательные поля
+	if _, ok := rd.peek(); ok {
+		e := &Error{Code: r.StatusCode}
+		e.Message, err = rd.string()
+		if err != nil {
+			return
+		}
+		e.Details, err = rd.stringMap()
+		if err != nil {
+			return
+		}
+		//Пустая ошибка пишется, если после нее есть метаданные
+		if r.StatusCode != StatusCodeOK || e.Message != "" || len(e.Details) > 0 {
+			r.Error = e
+		}
+	}
+	//9. metadata, необязательное поле
+	if _, ok := rd.peek(); ok {
+		r.Metadata, err = rd.stringMap()
+		if err != nil {
+			return
+		}
+	}
 
 	return
 }
@@ -122,6 +280,10 @@ func (r *Response) String() string {
 	if r.Data != nil {
 		data = fmt.Sprintf("%v", r.Data)
 	}
-	return fmt.Sprintf("id: %s, status_code: %s(%d), event: %s(%d), content_type: %s, data: %s",
-		r.Id, r.StatusCode.String(), r.StatusCode, EventToString(Events(r.Event)), r.Event, r.ContentType, data)
+	err := "null"
+	if r.Error != nil {
+		err = r.Error.Error()
+	}
+	return fmt.Sprintf("id: %s, status_code: %s(%d), event: %s(%d), content_type: %s, encoding: %s, metadata: %v, data: %s, error: %s",
+		r.Id, r.StatusCode.String(), r.StatusCode, EventToString(Events(r.Event)), r.Event, r.ContentType, r.Encoding, r.Metadata, data, err)
 }
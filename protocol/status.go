@@ -1,32 +1,69 @@
 package protocol
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+)
 
+//Код статуса ответа. Коды 0-2 совместимы с прежними версиями,
+//остальные передаются несколькими цифрами по аналогии с http
 type StatusCode int
 
 const (
 	StatusCodeOK StatusCode = iota
 	StatusCodeError
+	StatusCodeRateLimited
 )
 
+const (
+	StatusCodeBadRequest       StatusCode = 400
+	StatusCodeUnauthorized     StatusCode = 401
+	StatusCodeForbidden        StatusCode = 403
+	StatusCodeNotFound         StatusCode = 404
+	StatusCodeMethodNotAllowed StatusCode = 405
+	StatusCodeTimeout          StatusCode = 408
+	StatusCodeConflict         StatusCode = 409
+	StatusCodeInternal         StatusCode = 500
+	StatusCodeNotImplemented   StatusCode = 501
+	StatusCodeUnavailable      StatusCode = 503
+)
+
+var statusCodes = map[StatusCode]string{
+	StatusCodeOK:               "StatusCodeOK",
+	StatusCodeError:            "StatusCodeError",
+	StatusCodeRateLimited:      "StatusCodeRateLimited",
+	StatusCodeBadRequest:       "StatusCodeBadRequest",
+	StatusCodeUnauthorized:     "StatusCodeUnauthorized",
+	StatusCodeForbidden:        "StatusCodeForbidden",
+	StatusCodeNotFound:         "StatusCodeNotFound",
+	StatusCodeMethodNotAllowed: "StatusCodeMethodNotAllowed",
+	StatusCodeTimeout:          "StatusCodeTimeout",
+	StatusCodeConflict:         "StatusCodeConflict",
+	StatusCodeInternal:         "StatusCodeInternal",
+	StatusCodeNotImplemented:   "StatusCodeNotImplemented",
+	StatusCodeUnavailable:      "StatusCodeUnavailable",
+}
+
 func ToStatusCode(s string) StatusCode {
-	var code StatusCode
-	switch s {
-	case "0":
-		code = StatusCodeOK
-		break
-	default:
-		code = StatusCodeError
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return StatusCodeError
+	}
+	return toStatusCode(i)
+}
+
+//Неизвестный код - StatusCodeError
+func toStatusCode(i int) StatusCode {
+	if _, ok := statusCodes[StatusCode(i)]; ok {
+		return StatusCode(i)
 	}
-	return code
+	return StatusCodeError
 }
 
 func (sc StatusCode) String() string {
-	s := "StatusCodeError"
-	switch sc {
-	case StatusCodeOK:
-		s = "StatusCodeOK"
-		break
+	s, ok := statusCodes[sc]
+	if !ok {
+		s = "StatusCodeError"
 	}
 	return fmt.Sprintf("%s(%d)", s, sc)
 }
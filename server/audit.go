@@ -0,0 +1,51 @@
+package server
+
+import (
+	"time"
+
+	"github.com/egovorukhin/egoudp/audit"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Записываем событие подключения в журнал
+func (s *Server) audit(kind audit.Kind, c *Connection, changes []audit.Change, reason string) {
+	if s.Audit == nil {
+		return
+	}
+	err := s.Audit.Write(audit.Event{
+		Time:        time.Now(),
+		Kind:        kind,
+		Hostname:    c.Hostname,
+		IpAddress:   c.IpAddress.String(),
+		Domain:      c.Domain,
+		Login:       c.Login,
+		Version:     c.Version,
+		ConnectTime: c.ConnectTime,
+		Changes:     changes,
+		Reason:      reason,
+	})
+	if err != nil {
+		s.Printf("audit: %v\n", err)
+	}
+}
+
+//Ответ с кодом StatusCodeUnauthorized или StatusCodeForbidden
+//записывается в журнал как ошибка авторизации
+func (c *Connection) auditResponse(resp *protocol.Response) {
+	if resp.StatusCode != protocol.StatusCodeUnauthorized && resp.StatusCode != protocol.StatusCodeForbidden {
+		return
+	}
+	reason := resp.StatusCode.String()
+	if resp.Error != nil {
+		reason = resp.Error.Error()
+	}
+	c.audit(audit.KindAuthFailure, c, nil, reason)
+}
+
+//История подключений из журнала, если журнал поддерживает поиск
+func (s *Server) History(f audit.Filter) ([]audit.Event, error) {
+	if s.Audit == nil {
+		return nil, audit.ErrNotQueryable
+	}
+	return audit.Query(s.Audit, f)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/audit"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func TestAudit(t *testing.T) {
+	ring := audit.NewRing(16)
+	srv := New(Config{BufferSize: 1024, DisconnectTimeout: 30, Audit: ring}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("secret", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetError(protocol.NewError(protocol.StatusCodeForbidden, "Доступ запрещен")))
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: srv.listener.LocalAddr().(*net.UDPAddr).Port}
+	conn, err := net.DialUDP(udp, nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	send := func(login string, req *protocol.Request) {
+		packet := protocol.New("pc-1", login, "hq", "1.0.0")
+		packet.Request = req
+		if _, err := conn.Write(packet.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	send("user", nil)
+	send("admin", nil)
+	send("admin", protocol.NewRequest("secret", protocol.MethodGet))
+	_ = srv.Stop()
+
+	kinds := []audit.Kind{audit.KindConnected, audit.KindReconnected, audit.KindAuthFailure, audit.KindDisconnected}
+	events, err := srv.History(audit.Filter{Hostname: "PC-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(kinds) {
+		t.Fatalf("events: %v", events)
+	}
+	for i, kind := range kinds {
+		if events[i].Kind != kind {
+			t.Errorf("%d: %s, ожидалось %s", i, events[i].Kind, kind)
+		}
+	}
+	changes := events[1].Changes
+	if len(changes) != 1 || changes[0].Field != "login" || changes[0].Old != "user" || changes[0].New != "admin" {
+		t.Errorf("changes: %v", changes)
+	}
+}
+
+//Журнал закрывается при остановке сервера, события отключения в него попадают
+func TestAuditClose(t *testing.T) {
+	sink, err := audit.NewFile(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(Config{Host: "127.0.0.1", BufferSize: 1024, DisconnectTimeout: 30, Audit: sink}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	if err = srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if resp := sendRaw(t, dialRaw(t, srv), "pc-1", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	if err = srv.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(audit.Event{Kind: audit.KindConnected}); err == nil {
+		t.Error("журнал не закрыт")
+	}
+	events, err := srv.History(audit.Filter{Hostname: "PC-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].Kind != audit.KindDisconnected {
+		t.Errorf("events: %v", events)
+	}
+}
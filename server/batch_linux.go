@@ -0,0 +1,117 @@
+package server
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+//Пакетный ввод-вывод сокета, ipv4.Message и ipv6.Message - один тип
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+//Сокет IPv6, в том числе двойного стека, оборачиваем в ipv6.PacketConn
+func newBatchConn(listener net.PacketConn) batchConn {
+	if addr, ok := listener.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		return ipv6.NewPacketConn(listener)
+	}
+	return ipv4.NewPacketConn(listener)
+}
+
+//Прием пакетов пачками через recvmmsg. Для транспорта
+//отличного от UDP принимаем по одному пакету
+func (s *Server) receiveBatch(listener net.PacketConn) {
+
+	if _, ok := listener.(*net.UDPConn); !ok {
+		s.receive(listener)
+		return
+	}
+	conn := newBatchConn(listener)
+	messages := make([]ipv4.Message, s.BatchSize)
+	buffers := make([]*[]byte, s.BatchSize)
+
+	for {
+
+		for i := range messages {
+			if buffers[i] == nil {
+				buffers[i] = s.buffers.Get()
+			}
+			messages[i].Buffers = [][]byte{*buffers[i]}
+		}
+
+		n, err := conn.ReadBatch(messages, 0)
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			s.Printf("receiveBatch: %v\n", err)
+			continue
+		}
+
+		if !s.Started.Get() {
+			break
+		}
+
+		for i := 0; i < n; i++ {
+			if messages[i].Addr == nil {
+				continue
+			}
+			buffer := buffers[i]
+			buffers[i] = nil
+			s.dispatch(listener, messages[i].Addr, buffer, (*buffer)[:messages[i].N])
+		}
+	}
+
+	for _, buffer := range buffers {
+		if buffer != nil {
+			s.buffers.Put(buffer)
+		}
+	}
+}
+
+//Отправка пакетов нескольким адресатам через sendmmsg, возвращает
+//количество отправленных пакетов. Как и в writeEach, ошибка не прерывает
+//отправку остальных пакетов, возвращается первая ошибка
+func (s *Server) writeBatch(listener net.PacketConn, datagrams []datagram) (n int, err error) {
+
+	if _, ok := listener.(*net.UDPConn); !ok || s.BatchSize <= 1 {
+		return s.writeEach(listener, datagrams)
+	}
+
+	conn := newBatchConn(listener)
+	messages := make([]ipv4.Message, 0, s.BatchSize)
+
+	for len(datagrams) > 0 {
+		size := len(datagrams)
+		if size > s.BatchSize {
+			size = s.BatchSize
+		}
+		messages = messages[:0]
+		for _, d := range datagrams[:size] {
+			messages = append(messages, ipv4.Message{
+				Buffers: [][]byte{d.b},
+				Addr:    d.addr,
+			})
+		}
+		datagrams = datagrams[size:]
+
+		for sent := 0; sent < len(messages); {
+			//При ошибке WriteBatch возвращает -1
+			i, e := conn.WriteBatch(messages[sent:], 0)
+			if i > 0 {
+				sent += i
+				n += i
+			}
+			//Пакет, на котором sendmmsg вернул ошибку, пропускаем
+			if e != nil {
+				if err == nil {
+					err = e
+				}
+				sent++
+			}
+		}
+	}
+
+	return
+}
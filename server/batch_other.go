@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package server
+
+import "net"
+
+//Пакетный прием поддерживается только в linux
+func (s *Server) receiveBatch(listener net.PacketConn) {
+	s.receive(listener)
+}
+
+func (s *Server) writeBatch(listener net.PacketConn, datagrams []datagram) (int, error) {
+	return s.writeEach(listener, datagrams)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/pool"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Нагрузочный тест на loopback: клиенты шлют keep-alive пакеты,
+//сервер принимает их по одному, пачками или несколькими сокетами
+func benchmarkReceive(b *testing.B, batchSize, listeners int) {
+	srv := New(Config{
+		BufferSize:        1024,
+		DisconnectTimeout: 30,
+		BatchSize:         batchSize,
+		Listeners:         listeners,
+		Pool: pool.Config{
+			QueueSize: 8192,
+			Policy:    pool.PolicyDrop,
+		},
+	}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	if err := srv.Start(); err != nil {
+		b.Fatal(err)
+	}
+	defer srv.Stop()
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: srv.listener.LocalAddr().(*net.UDPAddr).Port}
+
+	//Ядро распределяет пакеты по сокетам по адресу отправителя,
+	//поэтому клиентов должно быть больше чем сокетов
+	const clients = 16
+	conns := make([]*net.UDPConn, clients)
+	packets := make([][]byte, clients)
+	for i := range conns {
+		conn, err := net.DialUDP(udp, nil, addr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+		packets[i] = protocol.New(fmt.Sprintf("bench-%d", i), "user", "HQ", "1.0.0").Marshal()
+	}
+
+	//Ждем пока сервер разберет не больше window пакетов от отправленных
+	received := func(sent, window int) uint64 {
+		var n uint64
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			m := srv.PoolMetrics()
+			n = m.Processed + m.Dropped
+			if n+uint64(window) >= uint64(sent) {
+				break
+			}
+			time.Sleep(10 * time.Microsecond)
+		}
+		return n
+	}
+
+	const window = 128
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		_, _ = conns[i%clients].Write(packets[i%clients])
+		if i%window == window-1 {
+			received(i+1, window)
+		}
+	}
+	n := received(b.N, 0)
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	b.ReportMetric(float64(n)/elapsed.Seconds(), "packets/s")
+	b.ReportMetric(float64(n)/float64(b.N)*100, "%received")
+}
+
+func BenchmarkReceive(b *testing.B) {
+	benchmarkReceive(b, 0, 0)
+}
+
+func BenchmarkReceiveBatch(b *testing.B) {
+	benchmarkReceive(b, 64, 0)
+}
+
+//Масштабирование по ядрам: по сокету с SO_REUSEPORT на ядро
+func BenchmarkReceiveListeners(b *testing.B) {
+	listeners := []int{1, 2, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		listeners = append(listeners, n)
+	}
+	for _, n := range listeners {
+		b.Run(fmt.Sprintf("listeners-%d", n), func(b *testing.B) {
+			benchmarkReceive(b, 0, n)
+		})
+	}
+}
+
+//Запросы принимаются через recvmmsg, ответы по логину уходят через sendmmsg
+func TestBatchRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		network string
+		host    string
+	}{
+		{"udp4", "127.0.0.1"},
+		{"udp6", "::1"},
+	} {
+		t.Run(tt.network, func(t *testing.T) {
+			if tt.network == "udp6" {
+				skipNoIPv6(t)
+			}
+			srv := New(Config{Network: tt.network, Host: tt.host, BufferSize: 1024, DisconnectTimeout: 30, BatchSize: 8}).(*Server)
+			srv.SetLogger(ioutil.Discard, "", 0)
+			srv.SetRoute("echo", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+				c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+			})
+			if err := srv.Start(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = srv.Stop()
+			})
+
+			//Адресатов больше чем BatchSize, отправка идет в несколько пачек
+			conns := make([]*net.UDPConn, 10)
+			for i := range conns {
+				conns[i] = dialRaw(t, srv)
+				hostname := fmt.Sprintf("pc-%d", i)
+				if resp := sendRaw(t, conns[i], hostname, protocol.EventConnected, nil); resp == nil {
+					t.Fatalf("%s: нет подключения", hostname)
+				}
+				id := fmt.Sprintf("%d", i)
+				if resp := sendRaw(t, conns[i], hostname, protocol.EventNone, echoRequest(id)); resp == nil || resp.Id != id || resp.Data.String() != id {
+					t.Fatalf("%s: ответ: %v", hostname, resp)
+				}
+			}
+
+			resp := &protocol.Response{StatusCode: protocol.StatusCodeOK, ContentType: "text/plain", Data: protocol.ToRunes("broadcast")}
+			if n := srv.SendByLogin("USER", resp); n != len(conns) {
+				t.Fatalf("SendByLogin: %d", n)
+			}
+			buffer := make([]byte, 1024)
+			for i, conn := range conns {
+				_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+				n, err := conn.Read(buffer)
+				if err != nil {
+					t.Fatalf("pc-%d: %v", i, err)
+				}
+				r := new(protocol.Response)
+				if err = r.Unmarshal(buffer[:n]); err != nil || r.Data.String() != "broadcast" {
+					t.Fatalf("pc-%d: %v, %v", i, r, err)
+				}
+			}
+		})
+	}
+}
+
+//Ошибка отправки одного пакета не прерывает отправку остальных
+func TestWriteContinues(t *testing.T) {
+	for _, batchSize := range []int{0, 8} {
+		t.Run(fmt.Sprintf("batch-%d", batchSize), func(t *testing.T) {
+			srv := New(Config{Network: "udp4", Host: "127.0.0.1", BufferSize: 1024, DisconnectTimeout: 30, BatchSize: batchSize}).(*Server)
+			srv.SetLogger(ioutil.Discard, "", 0)
+			if err := srv.Start(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = srv.Stop()
+			})
+			conns := []*net.UDPConn{dialRaw(t, srv), dialRaw(t, srv)}
+			//Адрес IPv6 через сокет IPv4 не отправляется
+			datagrams := []datagram{
+				{b: []byte("1"), addr: conns[0].LocalAddr()},
+				{b: []byte("x"), addr: &net.UDPAddr{IP: net.IPv6loopback, Port: 9}},
+				{b: []byte("2"), addr: conns[1].LocalAddr()},
+			}
+			n, err := srv.writeBatch(srv.listener, datagrams)
+			if n != 2 || err == nil {
+				t.Fatalf("writeBatch: %d, %v", n, err)
+			}
+			buffer := make([]byte, 16)
+			for i, conn := range conns {
+				_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+				if _, err := conn.Read(buffer); err != nil {
+					t.Errorf("%d: %v", i, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"github.com/egovorukhin/egoudp/capture"
+)
+
+//Передаем пакет в захват, addr - адрес клиента
+func (s *Server) capture(direction capture.Direction, addr net.Addr, data []byte) {
+	if s.Capture == nil {
+		return
+	}
+	s.Capture.Capture(capture.Record{
+		Time:      time.Now(),
+		Direction: direction,
+		Addr:      addr.String(),
+		Data:      data,
+	})
+}
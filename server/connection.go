@@ -2,8 +2,10 @@ package server
 
 import (
 	"fmt"
-	"github.com/egovorukhin/egotimer"
+	"github.com/egovorukhin/egoudp/audit"
+	"github.com/egovorukhin/egoudp/capture"
 	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/transport"
 	"net"
 	"strings"
 	"sync"
@@ -13,15 +15,50 @@ import (
 type Connection struct {
 	*Server
 	Hostname       string
-	IpAddress      *net.UDPAddr
+	IpAddress      net.Addr
+	Family         string
 	Domain         string
 	Login          string
 	ConnectTime    time.Time
 	DisconnectTime *time.Time
 	Version        string
-	timer          *egotimer.Timer
+	timer          *time.Ticker
+	stopTimer      chan struct{}
+	stopOnce       sync.Once
+	limiter        *Bucket
 	//ccTimer        *egotimer.Timer
 	Connected Connected
+	//Подключение восстановлено из хранилища и
+	//ожидает первого пакета от клиента
+	Pending Connected
+	stats   stats
+	//Сокет, на который приходят пакеты клиента, через него отвечаем
+	listener net.PacketConn
+}
+
+//Статистика подключения
+type Stats struct {
+	Received uint64
+	LastSeen time.Time
+}
+
+type stats struct {
+	sync.Mutex
+	value Stats
+}
+
+func (s *stats) add() {
+	s.Lock()
+	s.value.Received++
+	s.value.LastSeen = time.Now()
+	s.Unlock()
+}
+
+//Количество принятых пакетов и время последнего пакета
+func (c *Connection) Stats() Stats {
+	c.stats.Lock()
+	defer c.stats.Unlock()
+	return c.stats.value
 }
 
 type Connected struct {
@@ -41,16 +78,41 @@ func (c *Connected) Get() bool {
 	return c.value
 }
 
+//Таймер отключения: если за timeout от клиента не пришло ни одного пакета,
+//то отключаем его. Тикер создаем до запуска горутины, чтобы остановка
+//сразу после подключения не зависела от того, успела ли она запуститься
 func (c *Connection) startDTimer(timeout int) {
-	c.timer = egotimer.New(time.Duration(timeout)*time.Second, func(t time.Time) bool {
-		if !c.Connected.Get() {
-			c.disconnect()
-			return true
+	c.timer = time.NewTicker(time.Duration(timeout) * time.Second)
+	c.stopTimer = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c.timer.C:
+				if !c.Connected.Get() {
+					c.disconnect()
+					return
+				}
+				c.Connected.Set(false)
+			case <-c.stopTimer:
+				return
+			}
 		}
-		c.Connected.Set(false)
-		return false
+	}()
+}
+
+//Пакет клиента отброшен ограничением, но клиент на связи -
+//таймер не должен его отключить. Пакет с чужого адреса не учитываем
+func (c *Connection) alive(addr net.Addr) {
+	if c != nil && c.IpAddress.String() == addr.String() {
+		c.Connected.Set(true)
+	}
+}
+
+func (c *Connection) stopDTimer() {
+	c.stopOnce.Do(func() {
+		c.timer.Stop()
+		close(c.stopTimer)
 	})
-	go c.timer.Start()
 }
 
 //Стартуем check connection timer
@@ -62,19 +124,38 @@ func (c *Connection) startDTimer(timeout int) {
 	go c.ccTimer.Start()
 }*/
 
-func (c *Connection) updated(addr *net.UDPAddr, header protocol.Header) bool {
+//Возвращаем список изменений, пустой - данные не изменились
+func (c *Connection) updated(listener net.PacketConn, addr net.Addr, header protocol.Header) (changes []audit.Change) {
+
+	//Клиент с новым адресом или восстановленный из хранилища
+	//может прийти на другой сокет
+	if c.listener != listener {
+		c.listener = listener
+	}
 
 	if !c.equals(header) || !strings.EqualFold(c.IpAddress.String(), addr.String()) /*!c.IpAddress.IP.Equal(addr.IP)*/ {
+		changes = appendChange(changes, "ip_address", c.IpAddress.String(), addr.String())
+		changes = appendChange(changes, "family", c.Family, transport.Family(addr))
+		changes = appendChange(changes, "domain", c.Domain, header.Domain)
+		changes = appendChange(changes, "login", c.Login, header.Login)
+		changes = appendChange(changes, "version", c.Version, header.Version)
+
 		c.Hostname = header.Hostname
 		c.IpAddress = addr
+		c.Family = transport.Family(addr)
 		c.Domain = header.Domain
 		c.Login = header.Login
 		c.Version = header.Version
-
-		return true
 	}
 
-	return false
+	return
+}
+
+func appendChange(changes []audit.Change, field, old, new string) []audit.Change {
+	if old == new {
+		return changes
+	}
+	return append(changes, audit.Change{Field: field, Old: old, New: new})
 }
 
 func (c *Connection) equals(header protocol.Header) bool {
@@ -88,11 +169,17 @@ func (c *Connection) equals(header protocol.Header) bool {
 }
 
 func (c *Connection) disconnect() {
-	c.timer.Stop()
-	//c.ccTimer.Stop()
 	c.Send4(int(protocol.EventDisconnect))
+	c.drop()
+}
+
+//Отключение без уведомления клиента
+func (c *Connection) drop() {
+	c.stopDTimer()
+	//c.ccTimer.Stop()
 	t := time.Now()
 	c.DisconnectTime = &t
+	c.audit(audit.KindDisconnected, c, nil, "")
 	//Удаляем подключение из списка
 	c.deleteConnection(c.Hostname)
 	//событие при отключении
@@ -100,7 +187,28 @@ func (c *Connection) disconnect() {
 }
 
 func (c *Connection) Send(resp protocol.IResponse) (int, error) {
-	return c.listener.WriteToUDP(resp.Marshal(), c.IpAddress)
+	b, err := c.marshal(resp)
+	if err != nil || b == nil {
+		return 0, err
+	}
+	return c.listener.WriteTo(b, c.IpAddress)
+}
+
+//Ответ в том виде, в котором он уходит клиенту: сжатый,
+//записанный в аудит и захват. nil - ответ не отправляется
+func (c *Connection) marshal(resp protocol.IResponse) ([]byte, error) {
+	//На уведомления не отвечаем
+	if resp.IsOneWay() {
+		return nil, nil
+	}
+	r, err := resp.Compressed(c.Compression)
+	if err != nil {
+		return nil, err
+	}
+	c.auditResponse(r)
+	b := r.Marshal()
+	c.capture(capture.DirectionOut, c.IpAddress, b)
+	return b, nil
 }
 
 func (c *Connection) Send1(resp *protocol.Response) {
@@ -137,7 +245,7 @@ func (c *Connection) String() string {
 	if c.DisconnectTime != nil {
 		disconnect_time = c.DisconnectTime.Format("2006-01-02 15:04:05")
 	}
-	return fmt.Sprintf("hostname: %s, ip: %s, domain: %s, login: %s, version: %s, connected: %t, connect_time: %s, disconnect_time: %s",
-		c.Hostname, c.IpAddress.String(), c.Domain, c.Login, c.Version, c.Connected.value,
+	return fmt.Sprintf("hostname: %s, ip: %s, family: %s, domain: %s, login: %s, version: %s, connected: %t, pending: %t, connect_time: %s, disconnect_time: %s",
+		c.Hostname, c.IpAddress.String(), c.Family, c.Domain, c.Login, c.Version, c.Connected.Get(), c.Pending.Get(),
 		c.ConnectTime.Format("2006-01-02 15:04:05"), disconnect_time)
 }
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"github.com/egovorukhin/egoudp/discovery"
+	"github.com/egovorukhin/egoudp/transport"
+)
+
+//Отвечаем на запросы обнаружения адресом сервера,
+//именем (по умолчанию имя компьютера) и возможностями
+func (s *Server) startDiscovery() (err error) {
+	if s.Discovery == nil {
+		return nil
+	}
+	addr, ok := s.listener.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return errors.New("Обнаружение поддерживается только для UDP")
+	}
+	announcement := discovery.Announcement{
+		Name:    s.Discovery.Name,
+		Port:    addr.Port,
+		Network: s.Network,
+	}
+	if announcement.Name == "" {
+		announcement.Name, _ = os.Hostname()
+	}
+	if announcement.Network == "" {
+		announcement.Network = transport.NetworkUDP
+	}
+	//Сервер на конкретном адресе сообщает его,
+	//иначе клиент подключится по адресу ответа
+	if ip := net.ParseIP(s.Host); ip != nil && !ip.IsUnspecified() {
+		announcement.Host = s.Host
+	}
+	if s.Compression.Name != "" {
+		announcement.Capabilities = append(announcement.Capabilities, "compression:"+s.Compression.Name)
+	}
+	if s.Tracer != nil {
+		announcement.Capabilities = append(announcement.Capabilities, "trace")
+	}
+	s.discovery, err = discovery.Listen(*s.Discovery, announcement)
+	if err != nil {
+		return err
+	}
+	go func() {
+		_ = s.discovery.Serve()
+	}()
+	return nil
+}
+
+func (s *Server) stopDiscovery() error {
+	if s.discovery == nil {
+		return nil
+	}
+	err := s.discovery.Close()
+	s.discovery = nil
+	return err
+}
@@ -1,17 +1,24 @@
 package server
 
+import "github.com/egovorukhin/egoudp/protocol"
+
 //События сервера
 type HandleServer func(s *Server)
 
 //События подключений
 type HandleConnection func(c *Connection)
 
+//Событие превышения ограничения количества пакетов,
+//req - nil, если пакет не содержал запроса
+type HandleRateLimited func(c *Connection, scope LimitScope, req *protocol.Request)
+
 type Handler struct {
 	OnStart        HandleServer
 	OnStop         HandleServer
 	OnConnected    HandleConnection
 	OnReconnected  HandleConnection
 	OnDisconnected HandleConnection
+	OnRateLimited  HandleRateLimited
 }
 
 func (h *Handler) HandleStart(s *Server) {
@@ -44,12 +51,19 @@ func (h *Handler) HandleDisconnected(c *Connection) {
 	}
 }
 
+func (h *Handler) HandleRateLimited(c *Connection, scope LimitScope, req *protocol.Request) {
+	if h.OnRateLimited != nil {
+		go h.OnRateLimited(c, scope, req)
+	}
+}
+
 type IHandler interface {
 	HandleStart(s *Server)
 	HandleStop(s *Server)
 	HandleConnected(c *Connection)
 	HandleReconnected(c *Connection)
 	HandleDisconnected(c *Connection)
+	HandleRateLimited(c *Connection, scope LimitScope, req *protocol.Request)
 }
 
 func OnStart(handler IHandler, s *Server) {
@@ -71,3 +85,7 @@ func OnReconnected(handler IHandler, c *Connection) {
 func OnDisconnected(handler IHandler, c *Connection) {
 	handler.HandleDisconnected(c)
 }
+
+func OnRateLimited(handler IHandler, c *Connection, scope LimitScope, req *protocol.Request) {
+	handler.HandleRateLimited(c, scope, req)
+}
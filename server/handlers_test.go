@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/egovorukhin/egoudp/pool"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Медленный обработчик не занимает пул: с одним обработчиком пула
+//и PolicyBlock сервер отвечает на проверку подключения, а сверх
+//ограничения Handlers запрос отклоняется
+func TestHandlersOutsidePool(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv := New(Config{
+		Host:              "127.0.0.1",
+		BufferSize:        1024,
+		DisconnectTimeout: 30,
+		Pool:              pool.Config{Workers: 1, QueueSize: 1, Policy: pool.PolicyBlock},
+		Handlers:          1,
+	}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	started := make(chan struct{}, 1)
+	srv.SetRoute("slow", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	req := protocol.NewRequest("slow", protocol.MethodGet)
+	req.Id = "1"
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, req); resp != nil {
+		t.Fatalf("ответ: %v", resp)
+	}
+	<-started
+	for i := 0; i < 3; i++ {
+		if resp := sendRaw(t, conn, "pc-1", protocol.EventCheckConnection, nil); resp == nil || resp.Event != int(protocol.EventCheckConnection) {
+			t.Fatalf("проверка подключения: %v", resp)
+		}
+	}
+	req.Id = "2"
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, req); resp == nil || !errors.Is(resp.Err(), protocol.ErrUnavailable) {
+		t.Fatalf("ответ сверх ограничения: %v", resp)
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/transport"
+)
+
+func skipNoIPv6(t *testing.T) {
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("IPv6 недоступен: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func startHost(t *testing.T, network, host string) *Server {
+	srv := New(Config{Network: network, Host: host, BufferSize: 1024, DisconnectTimeout: 30}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+	return srv
+}
+
+//Подключаем клиента hostname к серверу по адресу host и возвращаем подключение на сервере
+func connectHost(t *testing.T, srv *Server, hostname, host string) *Connection {
+	clt := client.New(client.Config{
+		Host:       host,
+		Port:       srv.LocalAddr().(*net.UDPAddr).Port,
+		BufferSize: 1024,
+		Timeout:    3,
+	})
+	clt.SetLogger(ioutil.Discard, "", 0)
+	connected := make(chan bool, 1)
+	clt.OnConnected(func(c *client.Client) {
+		connected <- true
+	})
+	if err := clt.Start(hostname, "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clt.Stop)
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("%s: клиент не подключился к %s", hostname, host)
+	}
+	c, ok := srv.GetConnections()[hostname]
+	if !ok {
+		t.Fatalf("%s: подключение отсутствует", hostname)
+	}
+	return c
+}
+
+func TestIPv6Loopback(t *testing.T) {
+	skipNoIPv6(t)
+	srv := startHost(t, "", "::1")
+	if addr := srv.LocalAddr().(*net.UDPAddr); !addr.IP.Equal(net.IPv6loopback) {
+		t.Fatalf("LocalAddr: %v", addr)
+	}
+	for _, host := range []string{"::1", "[::1]"} {
+		c := connectHost(t, srv, "PC-"+host, host)
+		addr := c.IpAddress.(*net.UDPAddr)
+		if c.Family != transport.FamilyIPv6 || !addr.IP.Equal(net.IPv6loopback) {
+			t.Errorf("%s: %s", host, c)
+		}
+	}
+}
+
+//Сервер без адреса принимает и IPv4, и IPv6
+func TestDualStack(t *testing.T) {
+	skipNoIPv6(t)
+	srv := startHost(t, "", "")
+	if c := connectHost(t, srv, "PC-4", "127.0.0.1"); c.Family != transport.FamilyIPv4 {
+		t.Errorf("%s", c)
+	}
+	if c := connectHost(t, srv, "PC-6", "::1"); c.Family != transport.FamilyIPv6 {
+		t.Errorf("%s", c)
+	}
+}
+
+func TestBindAddress(t *testing.T) {
+	srv := startHost(t, transport.NetworkUDP4, "127.0.0.1")
+	if addr := srv.LocalAddr().(*net.UDPAddr); !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Fatalf("LocalAddr: %v", addr)
+	}
+	if c := connectHost(t, srv, "PC-1", "127.0.0.1"); c.Family != transport.FamilyIPv4 {
+		t.Errorf("%s", c)
+	}
+
+	srv = New(Config{Network: transport.NetworkUDP4, Host: "::1", BufferSize: 1024}).(*Server)
+	if err := srv.Start(); err == nil {
+		_ = srv.Stop()
+		t.Error("udp4 на IPv6 адресе")
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import "sync"
+
+//Промежуточный обработчик маршрутов. Вызывается перед обработчиком маршрута,
+//имеет доступ к запросу, его метаданным и ответу, может прервать обработку
+//не вызывая next
+type Middleware func(next FuncHandler) FuncHandler
+
+//Список промежуточных обработчиков, Use может вызываться во время обработки запросов
+type middlewares struct {
+	sync.RWMutex
+	list []Middleware
+}
+
+//Добавляем промежуточные обработчики, выполняются в порядке добавления
+func (s *Server) Use(middleware ...Middleware) {
+	s.middleware.Lock()
+	s.middleware.list = append(s.middleware.list, middleware...)
+	s.middleware.Unlock()
+}
+
+func (s *Server) wrap(handler FuncHandler) FuncHandler {
+	//append не меняет элементы в пределах прежней длины,
+	//поэтому копии среза достаточно
+	s.middleware.RLock()
+	list := s.middleware.list
+	s.middleware.RUnlock()
+	for i := len(list) - 1; i >= 0; i-- {
+		handler = list[i](handler)
+	}
+	return handler
+}
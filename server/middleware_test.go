@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Метаданные запроса проходят через промежуточные обработчики в обработчик,
+//метаданные ответа возвращаются клиенту
+func TestMiddlewareMetadata(t *testing.T) {
+	srv := startHost(t, "", "127.0.0.1")
+	srv.SetRoute("locale", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, protocol.ToRunes(req.GetMetadata("locale")+","+req.GetMetadata("user"))))
+	})
+	//Проверка токена, прерывает обработку
+	srv.Use(func(next FuncHandler) FuncHandler {
+		return func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+			if req.GetMetadata("token") != "secret" {
+				_, _ = c.Send(resp.SetError(protocol.NewError(protocol.StatusCodeUnauthorized, "Нет токена")))
+				return
+			}
+			next(c, resp, *req.SetMetadata("user", "admin"))
+		}
+	})
+	//Метаданные ответа, выполняется после проверки токена
+	srv.Use(func(next FuncHandler) FuncHandler {
+		return func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+			resp.SetMetadata("server", "egoudp").SetMetadata("trace", req.GetMetadata("trace"))
+			next(c, resp, req)
+		}
+	})
+
+	clt := client.New(client.Config{
+		Host:       "127.0.0.1",
+		Port:       srv.LocalAddr().(*net.UDPAddr).Port,
+		BufferSize: 1024,
+		Timeout:    3,
+		Metadata:   map[string]string{"locale": "ru-RU", "token": "secret"},
+	}).(*client.Client)
+	clt.SetLogger(ioutil.Discard, "", 0)
+	connected := make(chan bool, 1)
+	clt.OnConnected(func(c *client.Client) {
+		connected <- true
+	})
+	if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clt.Stop)
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("клиент не подключился")
+	}
+
+	resp, err := clt.Send(protocol.NewRequest("locale", protocol.MethodGet).SetMetadata("trace", "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Err() != nil || resp.Data.String() != "ru-RU,admin" {
+		t.Fatalf("ответ: %v", resp)
+	}
+	if resp.GetMetadata("server") != "egoudp" || resp.GetMetadata("trace") != "abc" {
+		t.Errorf("метаданные ответа: %v", resp.Metadata)
+	}
+
+	//Значение из запроса важнее метаданных по умолчанию
+	resp, err = clt.Send(protocol.NewRequest("locale", protocol.MethodGet).SetMetadata("token", "wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(resp.Err(), protocol.ErrUnauthorized) || resp.GetMetadata("server") != "" {
+		t.Errorf("ответ: %v", resp)
+	}
+}
+
+//Use во время обработки запросов
+func TestMiddlewareUseConcurrent(t *testing.T) {
+	srv := startHost(t, "", "127.0.0.1")
+	srv.SetRoute("hi", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, nil))
+	})
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			srv.Use(func(next FuncHandler) FuncHandler {
+				return next
+			})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		req := protocol.NewRequest("hi", protocol.MethodGet)
+		req.Id = "1"
+		if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, req); resp == nil || resp.Err() != nil {
+			t.Fatalf("ответ: %v", resp)
+		}
+	}
+	wg.Wait()
+}
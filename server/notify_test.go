@@ -0,0 +1,90 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Уведомление доходит до обработчика, но ответ на него не отправляется,
+//даже если обработчик отвечает
+func TestNotify(t *testing.T) {
+	srv := startHost(t, "", "127.0.0.1")
+	notified := make(chan string, 4)
+	srv.SetRoute("event", protocol.MethodNotify, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		notified <- req.Data.String()
+		c.Send1(resp.SetData(protocol.StatusCodeOK, protocol.ToRunes("ack")))
+	})
+	clt, _ := startClient(t, srv)
+	pushed := make(chan *protocol.Response, 4)
+	clt.OnPush(func(c *client.Client, resp *protocol.Response) {
+		pushed <- resp
+	})
+
+	resp, err := clt.Send(protocol.NewRequest("event", protocol.MethodNotify).SetData("text/plain", protocol.ToRunes("started")))
+	if resp != nil || err != nil {
+		t.Fatalf("Send: %v, %v", resp, err)
+	}
+	select {
+	case data := <-notified:
+		if data != "started" {
+			t.Errorf("данные: %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("уведомление не доставлено")
+	}
+	select {
+	case resp := <-pushed:
+		t.Errorf("ответ на уведомление: %v", resp)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	//Без клиента: в сокет не приходит ни одного пакета
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-2", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	req := protocol.NewRequest("event", protocol.MethodNotify)
+	req.Id = "1"
+	if resp := sendRaw(t, conn, "pc-2", protocol.EventNone, req); resp != nil {
+		t.Errorf("ответ на уведомление: %v", resp)
+	}
+	<-notified
+}
+
+//Уведомление, отправленное перед остановкой клиента, доходит до сервера,
+//а Stop возвращается после отправки события отключения
+func TestNotifyStop(t *testing.T) {
+	srv := startHost(t, "", "127.0.0.1")
+	notified := make(chan string, 1)
+	srv.SetRoute("event", protocol.MethodNotify, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		notified <- req.Data.String()
+	})
+	clt, _ := startClient(t, srv)
+
+	if _, err := clt.Send(protocol.NewRequest("event", protocol.MethodNotify).SetData("text/plain", protocol.ToRunes("stopped"))); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	clt.Stop()
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Stop: %v", d)
+	}
+	select {
+	case data := <-notified:
+		if data != "stopped" {
+			t.Errorf("данные: %s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("уведомление не доставлено")
+	}
+	deadline := time.Now().Add(time.Second)
+	for _, ok := srv.Connections.Load("PC-1"); ok; _, ok = srv.Connections.Load("PC-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("сервер не получил событие отключения")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
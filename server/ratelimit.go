@@ -0,0 +1,162 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+//Ограничение количества пакетов.
+//Rate - пакетов в секунду, Burst - допустимый всплеск.
+//Rate <= 0 - ограничение отключено
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) IsNil() bool {
+	return l.Rate <= 0
+}
+
+type RateLimit struct {
+	//Общее ограничение на все входящие пакеты сервера
+	Global Limit
+	//Ограничение для каждого подключения, учитываются все пакеты, включая keep-alive
+	Connection Limit
+	//Ограничение новых подключений с одного адреса (IP без порта)
+	Source Limit
+}
+
+//Уровень на котором сработало ограничение
+type LimitScope int
+
+const (
+	LimitScopeGlobal LimitScope = iota
+	LimitScopeConnection
+	LimitScopeRoute
+	LimitScopeSource
+)
+
+func (l LimitScope) String() string {
+	s := "LimitScopeGlobal"
+	switch l {
+	case LimitScopeConnection:
+		s = "LimitScopeConnection"
+		break
+	case LimitScopeRoute:
+		s = "LimitScopeRoute"
+		break
+	case LimitScopeSource:
+		s = "LimitScopeSource"
+		break
+	}
+	return fmt.Sprintf("%s(%d)", s, l)
+}
+
+//Token bucket
+type Bucket struct {
+	sync.Mutex
+	Limit
+	tokens float64
+	last   time.Time
+}
+
+//nil - ограничение отключено
+func NewBucket(limit Limit) *Bucket {
+	if limit.IsNil() {
+		return nil
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = 1
+	}
+	return &Bucket{
+		Limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *Bucket) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *Bucket) refill() {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.Rate
+	if b.tokens > float64(b.Burst) {
+		b.tokens = float64(b.Burst)
+	}
+	b.last = now
+}
+
+//Корзина заполнена - источник давно не присылал пакетов
+func (b *Bucket) full() bool {
+	b.Lock()
+	defer b.Unlock()
+	b.refill()
+	return b.tokens >= float64(b.Burst)
+}
+
+//Сколько адресов источников хранится одновременно
+const maxSources = 4096
+
+//Ограничение новых подключений по адресу источника
+type sources struct {
+	sync.Mutex
+	limit   Limit
+	buckets map[string]*Bucket
+}
+
+//nil - ограничение отключено
+func newSources(limit Limit) *sources {
+	if limit.IsNil() {
+		return nil
+	}
+	return &sources{
+		limit:   limit,
+		buckets: map[string]*Bucket{},
+	}
+}
+
+//Если таблица адресов заполнена, то удаляем адреса с полными корзинами,
+//а пока места нет - новые адреса не пропускаем
+func (s *sources) Allow(addr net.Addr) bool {
+	if s == nil {
+		return true
+	}
+	key := addr.String()
+	if a, ok := addr.(*net.UDPAddr); ok {
+		key = a.IP.String()
+	}
+	s.Lock()
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= maxSources {
+			for k, v := range s.buckets {
+				if v.full() {
+					delete(s.buckets, k)
+				}
+			}
+			if len(s.buckets) >= maxSources {
+				s.Unlock()
+				return false
+			}
+		}
+		b = NewBucket(s.limit)
+		s.buckets[key] = b
+	}
+	s.Unlock()
+	return b.Allow()
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func TestBucketRefill(t *testing.T) {
+	if b := NewBucket(Limit{}); b != nil || !b.Allow() {
+		t.Fatal("ограничение отключено")
+	}
+	b := NewBucket(Limit{Rate: 20, Burst: 2})
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("всплеск Burst должен пропускаться")
+	}
+	if b.Allow() {
+		t.Fatal("корзина пуста")
+	}
+	//20 пакетов в секунду - один токен за 50мс
+	time.Sleep(70 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("корзина не пополнилась")
+	}
+	if b.Allow() {
+		t.Fatal("пополнился только один токен")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !b.full() {
+		t.Fatal("корзина не больше Burst")
+	}
+}
+
+type limited struct {
+	conn  *Connection
+	scope LimitScope
+}
+
+//Сервер с ограничениями и маршрутом echo, сработавшие ограничения в канале
+func startLimited(t *testing.T, limit RateLimit) (*Server, chan limited) {
+	srv := New(Config{Host: "127.0.0.1", BufferSize: 1024, DisconnectTimeout: 30, RateLimit: limit}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("echo", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+	})
+	ch := make(chan limited, 16)
+	srv.OnRateLimited(func(c *Connection, scope LimitScope, req *protocol.Request) {
+		ch <- limited{c, scope}
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+	return srv, ch
+}
+
+//Сокет без клиента, чтобы пакеты не повторялись и не добавлялись keep-alive
+func dialRaw(t *testing.T, srv *Server) *net.UDPConn {
+	conn, err := net.DialUDP("udp", nil, srv.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+//Отправляем пакет от hostname и ждем ответ, nil - ответа нет
+func sendRaw(t *testing.T, conn *net.UDPConn, hostname string, event protocol.Events, req *protocol.Request) *protocol.Response {
+	t.Helper()
+	p := protocol.New(hostname, "user", "HQ", "1.0.0")
+	p.Event = int(event)
+	p.Request = req
+	if _, err := conn.Write(p.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil
+	}
+	resp := new(protocol.Response)
+	if err = resp.Unmarshal(buffer[:n]); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func echoRequest(id string) *protocol.Request {
+	req := protocol.NewRequest("echo", protocol.MethodGet).SetData("text/plain", protocol.ToRunes(id))
+	req.Id = id
+	return req
+}
+
+func expectLimited(t *testing.T, ch chan limited, scope LimitScope) limited {
+	t.Helper()
+	select {
+	case l := <-ch:
+		if l.scope != scope {
+			t.Fatalf("ограничение: %s, ожидалось %s", l.scope, scope)
+		}
+		return l
+	case <-time.After(time.Second):
+		t.Fatalf("ограничение %s не сработало", scope)
+	}
+	return limited{}
+}
+
+//Сверх общего ограничения новый клиент не подключается
+func TestRateLimitGlobal(t *testing.T) {
+	srv, ch := startLimited(t, RateLimit{Global: Limit{Rate: 0.001, Burst: 2}})
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventConnected, nil); resp == nil || resp.Event != int(protocol.EventConnected) {
+		t.Fatalf("подключение: %v", resp)
+	}
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, echoRequest("1")); resp == nil || resp.Err() != nil {
+		t.Fatalf("ответ: %v", resp)
+	}
+	if resp := sendRaw(t, dialRaw(t, srv), "pc-2", protocol.EventConnected, nil); resp != nil {
+		t.Fatalf("ответ сверх ограничения: %v", resp)
+	}
+	if l := expectLimited(t, ch, LimitScopeGlobal); l.conn != nil {
+		t.Errorf("подключение: %s", l.conn.Hostname)
+	}
+	if _, ok := srv.Connections.Load("PC-2"); ok {
+		t.Error("подключение создано сверх ограничения")
+	}
+	//Отброшенный пакет подключенного клиента продлевает подключение
+	v, _ := srv.Connections.Load("PC-1")
+	v.(*Connection).Connected.Set(false)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventCheckConnection, nil); resp != nil {
+		t.Fatalf("ответ сверх ограничения: %v", resp)
+	}
+	expectLimited(t, ch, LimitScopeGlobal)
+	if !v.(*Connection).Connected.Get() {
+		t.Error("подключение не продлено")
+	}
+}
+
+//Подключенный клиент сверх своего ограничения получает ошибку
+func TestRateLimitConnection(t *testing.T) {
+	srv, ch := startLimited(t, RateLimit{Connection: Limit{Rate: 0.001, Burst: 2}})
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, echoRequest("1")); resp == nil || resp.Err() != nil {
+		t.Fatalf("ответ: %v", resp)
+	}
+	resp := sendRaw(t, conn, "pc-1", protocol.EventNone, echoRequest("2"))
+	if resp == nil || resp.StatusCode != protocol.StatusCodeRateLimited || resp.Id != "2" {
+		t.Fatalf("ответ: %v", resp)
+	}
+	if l := expectLimited(t, ch, LimitScopeConnection); l.conn == nil || l.conn.Hostname != "PC-1" {
+		t.Errorf("подключение: %v", l.conn)
+	}
+	//Ограничение одного клиента не касается других
+	if resp := sendRaw(t, dialRaw(t, srv), "pc-2", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения pc-2")
+	}
+}
+
+//С одного адреса подключается не больше Burst новых клиентов,
+//уже подключенные клиенты работают
+func TestRateLimitSource(t *testing.T) {
+	srv, ch := startLimited(t, RateLimit{Source: Limit{Rate: 0.001, Burst: 1}})
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	if resp := sendRaw(t, dialRaw(t, srv), "pc-2", protocol.EventConnected, nil); resp != nil {
+		t.Fatalf("ответ сверх ограничения: %v", resp)
+	}
+	if l := expectLimited(t, ch, LimitScopeSource); l.conn != nil {
+		t.Errorf("подключение: %s", l.conn.Hostname)
+	}
+	if _, ok := srv.Connections.Load("PC-2"); ok {
+		t.Error("подключение создано сверх ограничения")
+	}
+	for _, id := range []string{"1", "2"} {
+		if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, echoRequest(id)); resp == nil || resp.Err() != nil {
+			t.Fatalf("ответ: %v", resp)
+		}
+	}
+}
+
+func TestRateLimitRoute(t *testing.T) {
+	srv, ch := startLimited(t, RateLimit{})
+	if err := srv.SetRouteLimit("echo", protocol.MethodGet, Limit{Rate: 0.001, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	conn := dialRaw(t, srv)
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventConnected, nil); resp == nil {
+		t.Fatal("нет подключения")
+	}
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, echoRequest("1")); resp == nil || resp.Err() != nil {
+		t.Fatalf("ответ: %v", resp)
+	}
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventNone, echoRequest("2")); resp == nil || resp.StatusCode != protocol.StatusCodeRateLimited {
+		t.Fatalf("ответ: %v", resp)
+	}
+	expectLimited(t, ch, LimitScopeRoute)
+	//Keep-alive маршрутом не ограничивается
+	if resp := sendRaw(t, conn, "pc-1", protocol.EventCheckConnection, nil); resp == nil {
+		t.Fatal("нет ответа на проверку")
+	}
+}
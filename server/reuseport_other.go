@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+func listenReusePort(network string, addr *net.UDPAddr, n int) ([]net.PacketConn, error) {
+	return nil, errors.New("SO_REUSEPORT не поддерживается в этой ОС")
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Клиенты распределяются по сокетам, и каждому
+//подключению отвечает сокет, на который пришел запрос
+func TestListenersShard(t *testing.T) {
+	//В остальных ОС SO_REUSEPORT либо нет, либо пакеты
+	//получает один сокет, а не распределяются между сокетами
+	if runtime.GOOS != "linux" {
+		t.Skipf("распределение пакетов по сокетам не поддерживается в %s", runtime.GOOS)
+	}
+	const listeners = 4
+	srv := New(Config{Host: "127.0.0.1", BufferSize: 1024, DisconnectTimeout: 30, Listeners: listeners}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("echo", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+	})
+	if err := srv.Start(); err != nil {
+		t.Skipf("SO_REUSEPORT: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+	if len(srv.listeners) != listeners {
+		t.Fatalf("сокетов: %d", len(srv.listeners))
+	}
+
+	//Ядро выбирает сокет по адресу отправителя,
+	//поэтому клиентов больше чем сокетов
+	used := map[net.PacketConn]int{}
+	for i := 0; i < 32; i++ {
+		hostname := fmt.Sprintf("pc-%d", i)
+		conn := dialRaw(t, srv)
+		if resp := sendRaw(t, conn, hostname, protocol.EventConnected, nil); resp == nil {
+			t.Fatalf("%s: нет подключения", hostname)
+		}
+		id := fmt.Sprintf("%d", i)
+		if resp := sendRaw(t, conn, hostname, protocol.EventNone, echoRequest(id)); resp == nil || resp.Data.String() != id {
+			t.Fatalf("%s: ответ: %v", hostname, resp)
+		}
+		v, ok := srv.Connections.Load(strings.ToUpper(hostname))
+		if !ok {
+			t.Fatalf("%s: нет подключения на сервере", hostname)
+		}
+		used[v.(*Connection).listener]++
+	}
+	for listener := range used {
+		found := false
+		for _, l := range srv.listeners {
+			found = found || l == listener
+		}
+		if !found {
+			t.Fatal("подключение отвечает через чужой сокет")
+		}
+	}
+	if len(used) < 2 {
+		t.Errorf("все подключения на одном сокете: %v", used)
+	}
+}
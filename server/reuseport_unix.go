@@ -0,0 +1,46 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package server
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+//Открываем n сокетов на одном адресе с SO_REUSEPORT
+func listenReusePort(network string, addr *net.UDPAddr, n int) ([]net.PacketConn, error) {
+
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) (err error) {
+			e := c.Control(func(fd uintptr) {
+				err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			})
+			if e != nil {
+				return e
+			}
+			return
+		},
+	}
+
+	listeners := make([]net.PacketConn, 0, n)
+	for i := 0; i < n; i++ {
+		//Для порта 0 остальные сокеты открываем на порту первого
+		if i == 1 && addr.Port == 0 {
+			addr = &net.UDPAddr{IP: addr.IP, Port: listeners[0].LocalAddr().(*net.UDPAddr).Port, Zone: addr.Zone}
+		}
+		conn, err := config.ListenPacket(context.Background(), network, addr.String())
+		if err != nil {
+			for _, listener := range listeners {
+				_ = listener.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, conn)
+	}
+
+	return listeners, nil
+}
@@ -12,6 +12,8 @@ type Route struct {
 	Path    string
 	Method  protocol.Methods
 	Handler FuncHandler
+	Limit   Limit
+	limiter *Bucket
 }
 
 func (r *Route) String() string {
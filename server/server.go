@@ -1,14 +1,20 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/egovorukhin/egoudp/audit"
+	"github.com/egovorukhin/egoudp/capture"
+	"github.com/egovorukhin/egoudp/discovery"
+	"github.com/egovorukhin/egoudp/pool"
 	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/trace"
+	"github.com/egovorukhin/egoudp/transport"
 	"io"
 	"log"
 	"net"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,22 +22,70 @@ import (
 
 const udp = "udp"
 
+//Количество одновременно выполняющихся обработчиков маршрутов по умолчанию
+const DefaultHandlers = 1024
+
 type Server struct {
 	Connections sync.Map
-	listener    *net.UDPConn
+	listener    net.PacketConn
+	listeners   []net.PacketConn
+	discovery   *discovery.Responder
 	Started     Started
+	inFlight    InFlight
+	stopOnce    sync.Once
+	pool        *pool.Pool
+	handlers    chan struct{}
+	buffers     *pool.Buffers
+	limiter     *Bucket
+	sources     *sources
+	ctx         context.Context
+	cancel      context.CancelFunc
 	Router      sync.Map
+	middleware  middlewares
 	Handler     *Handler
 	*log.Logger
 	Config
 }
 
 type Config struct {
+	//Сеть: udp (по умолчанию, IPv4 и IPv6), udp4, udp6 или unixgram.
+	//Host - адрес интерфейса (пусто - все интерфейсы), для unixgram
+	//путь к файлу сокета, Port в этом случае не используется
+	Network                string
+	Host                   string
 	Port                   int
 	BufferSize             int
 	DisconnectTimeout      int
 	CheckConnectionTimeout int
 	LogLevel               LogLevel
+	//Пул обработчиков входящих пакетов
+	Pool pool.Config
+	//Количество одновременно выполняющихся обработчиков маршрутов, по умолчанию
+	//DefaultHandlers. Обработчики выполняются вне пула, сверх ограничения
+	//клиенту возвращается StatusCodeUnavailable
+	Handlers int
+	//Ограничение количества входящих пакетов
+	RateLimit RateLimit
+	//Количество пакетов читаемых/отправляемых за один системный вызов
+	//(recvmmsg/sendmmsg, только linux), 0 или 1 - по одному пакету
+	BatchSize int
+	//Количество сокетов на одном порту (SO_REUSEPORT), у каждого свой цикл приема.
+	//0 или 1 - один сокет
+	Listeners int
+	//Сжатие ответов, если клиент его поддерживает
+	Compression protocol.Compression
+	//Трассировка запросов, nil - отключена
+	Tracer *trace.Tracer
+	//Хранилище подключений для восстановления после перезапуска, nil - не сохраняются
+	Store ConnectionStore
+	//Журнал подключений, nil - не ведется
+	Audit audit.Sink
+	//Захват входящих и исходящих пакетов, nil - отключен
+	Capture capture.Sink
+	//Транспорт пакетов, nil - UDP
+	Transport transport.Transport
+	//Ответы на запросы обнаружения сервера клиентами, nil - отключены
+	Discovery *discovery.Config
 }
 
 type Started struct {
@@ -60,17 +114,25 @@ const (
 
 type IServer interface {
 	GetConnections() map[string]*Connection
+	History(f audit.Filter) ([]audit.Event, error)
 	GetRoutes() map[string]*Route
+	IsStarted() bool
+	PoolMetrics() pool.Metrics
 	SetLogger(out io.Writer, prefix string, flag int)
 	Start() error
 	Stop() error
+	Shutdown(ctx context.Context) error
 	Send(hostname string, resp *protocol.Response) (int, error)
 	SendByLogin(login string, response *protocol.Response) int
+	Disconnect(hostname string) error
 	SetRoute(path string, method protocol.Methods, handler FuncHandler)
+	SetRouteLimit(path string, method protocol.Methods, limit Limit) error
+	Use(middleware ...Middleware)
 	OnStart(handler HandleServer)
 	OnStop(handler HandleServer)
 	OnConnected(handler HandleConnection)
 	OnDisconnected(handler HandleConnection)
+	OnRateLimited(handler HandleRateLimited)
 }
 
 func New(config Config) IServer {
@@ -89,17 +151,83 @@ func (s *Server) SetLogger(out io.Writer, prefix string, flag int) {
 
 func (s *Server) Start() (err error) {
 
-	localAddr, err := net.ResolveUDPAddr(udp, ":"+strconv.Itoa(s.Port))
+	network := s.Network
+	if network == "" {
+		network = transport.NetworkUDP
+	}
+	address := s.Host
+	if network != transport.NetworkUnixgram {
+		address = transport.JoinHostPort(s.Host, s.Port)
+	}
+
+	if s.Listeners > 1 {
+		if s.Transport != nil || network == transport.NetworkUnixgram {
+			return errors.New("Несколько сокетов на одном порту поддерживаются только для UDP")
+		}
+		localAddr, err := net.ResolveUDPAddr(network, address)
+		if err != nil {
+			return err
+		}
+		s.listeners, err = listenReusePort(network, localAddr, s.Listeners)
+		if err != nil {
+			return err
+		}
+	} else {
+		t := s.Transport
+		if t == nil {
+			t, err = transport.New(s.Network)
+			if err != nil {
+				return err
+			}
+		}
+		listener, err := t.Listen(address)
+		if err != nil {
+			return err
+		}
+		s.listeners = []net.PacketConn{listener}
+	}
+	//Общие отправки идут через первый сокет, ответы
+	//подключению - через сокет, на который оно пришло
+	s.listener = s.listeners[0]
+
+	err = s.startDiscovery()
 	if err != nil {
+		for _, listener := range s.listeners {
+			_ = listener.Close()
+		}
 		return err
 	}
 
-	s.listener, err = net.ListenUDP(udp, localAddr)
+	s.inFlight.Open()
+	s.stopOnce = sync.Once{}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	s.limiter = NewBucket(s.RateLimit.Global)
+	s.sources = newSources(s.RateLimit.Source)
+
+	s.buffers = pool.NewBuffers(s.BufferSize)
+	s.pool = pool.New(s.Pool)
+	s.pool.Start()
+	if s.Handlers <= 0 {
+		s.Handlers = DefaultHandlers
+	}
+	s.handlers = make(chan struct{}, s.Handlers)
+
+	err = s.restoreConnections()
 	if err != nil {
-		return err
+		s.Printf("restore: %v\n", err)
 	}
 
-	go s.receive()
+	//Пакетный прием доступен только в linux,
+	//в остальных случаях принимаем по одному пакету
+	for _, listener := range s.listeners {
+		if s.BatchSize > 1 {
+			go s.receiveBatch(listener)
+		} else {
+			go s.receive(listener)
+		}
+	}
 
 	s.Started.Set(true)
 
@@ -108,41 +236,68 @@ func (s *Server) Start() (err error) {
 	return
 }
 
-func (s *Server) receive() {
+func (s *Server) receive(listener net.PacketConn) {
 
 	for {
 
-		buffer := make([]byte, s.BufferSize)
+		buffer := s.buffers.Get()
 
-		n, addr, err := s.listener.ReadFromUDP(buffer)
+		n, from, err := listener.ReadFrom(*buffer)
 		if err != nil && !errors.Is(err, net.ErrClosed) {
+			s.buffers.Put(buffer)
 			s.Printf("receive: %v\n", err)
 			continue
 		}
 
 		if !s.Started.Get() {
+			s.buffers.Put(buffer)
 			break
 		}
 
-		if s.LogLevel == LogLevelHigh {
-			s.Println("receive: %s(%d)", string(buffer[:n]), n)
+		//Клиенту без адреса ответить не сможем
+		if from == nil {
+			s.buffers.Put(buffer)
+			continue
 		}
+		s.dispatch(listener, from, buffer, (*buffer)[:n])
+	}
+}
+
+//Передаем данные в пул и разбираем их,
+//после разбора буфер возвращаем в пул
+func (s *Server) dispatch(listener net.PacketConn, addr net.Addr, buffer *[]byte, data []byte) {
 
-		//Передаем данные и разбираем их
-		go s.parse(addr, buffer[:n])
+	s.capture(capture.DirectionIn, addr, data)
+
+	if s.LogLevel == LogLevelHigh {
+		s.Printf("receive: %s(%d)\n", string(data), len(data))
+	}
+
+	ok := s.pool.Submit(func() {
+		s.parse(listener, addr, data)
+		s.buffers.Put(buffer)
+	})
+	if !ok {
+		s.buffers.Put(buffer)
+		if s.LogLevel == LogLevelHigh {
+			s.Printf("receive: пакет отброшен, очередь переполнена (%s)\n", addr.String())
+		}
 	}
 }
 
-func (s *Server) newConnection(addr *net.UDPAddr, header protocol.Header) *Connection {
+func (s *Server) newConnection(listener net.PacketConn, addr net.Addr, header protocol.Header) *Connection {
 
 	conn := &Connection{
 		Server:      s,
+		listener:    listener,
 		Hostname:    header.Hostname,
 		IpAddress:   addr,
+		Family:      transport.Family(addr),
 		Domain:      header.Domain,
 		Login:       header.Login,
 		ConnectTime: time.Now(),
 		Version:     header.Version,
+		limiter:     NewBucket(s.RateLimit.Connection),
 		Connected: Connected{
 			value: true,
 		},
@@ -159,9 +314,17 @@ func (s *Server) newConnection(addr *net.UDPAddr, header protocol.Header) *Conne
 
 func (s *Server) deleteConnection(hostname string) {
 	s.Connections.Delete(hostname)
+	if s.Store != nil {
+		err := s.Store.Delete(hostname)
+		if err != nil {
+			s.Printf("store: %s: %v\n", hostname, err)
+		}
+	}
 }
 
-func (s *Server) parse(addr *net.UDPAddr, buffer []byte) {
+func (s *Server) parse(listener net.PacketConn, addr net.Addr, buffer []byte) {
+
+	start := time.Now()
 
 	packet := new(protocol.Packet)
 	err := packet.Unmarshal(buffer)
@@ -169,6 +332,16 @@ func (s *Server) parse(addr *net.UDPAddr, buffer []byte) {
 		return
 	}
 
+	if packet.Request != nil {
+		err = packet.Request.Decompress()
+		dispatch := s.traceParse(packet.Request, start, err)
+		defer dispatch.Finish()
+		if err != nil {
+			s.Printf("parse: %v\n", err)
+			return
+		}
+	}
+
 	if !packet.Header.IsNil() {
 		//Приводим к правильному формату данные - эстетика
 		packet.Header.Login = strings.ToLower(packet.Header.Login)
@@ -176,23 +349,57 @@ func (s *Server) parse(addr *net.UDPAddr, buffer []byte) {
 		packet.Header.Hostname = strings.ToUpper(packet.Header.Hostname)
 
 		//Подключаемся
-		go s.do(addr, packet)
+		s.do(listener, addr, packet)
 	}
 }
 
-func (s *Server) do(addr *net.UDPAddr, packet *protocol.Packet) {
+func (s *Server) do(listener net.PacketConn, addr net.Addr, packet *protocol.Packet) {
 
 	//Инициализируем ответ
 	resp := protocol.NewResponse(packet.Request, packet.Header.Event)
-	//Установка/проверка подключения
-	conn := s.setConnection(addr, packet)
+
+	//Ограничения проверяем до создания и обновления подключения,
+	//чтобы поток событий подключения и новых имен не расходовал память.
+	//Сверх ограничения отбрасываются и события, и запросы
+	var conn *Connection
+	if v, ok := s.Connections.Load(packet.Header.Hostname); ok {
+		conn = v.(*Connection)
+	}
+	if !s.limiter.Allow() {
+		conn.alive(addr)
+		s.rateLimited(conn, resp, packet.Request, LimitScopeGlobal)
+		return
+	}
+	if conn == nil {
+		//Сервер останавливается - новых клиентов не подключаем
+		if s.inFlight.Closed() {
+			return
+		}
+		if !s.sources.Allow(addr) {
+			s.rateLimited(nil, resp, packet.Request, LimitScopeSource)
+			return
+		}
+	} else if !conn.limiter.Allow() {
+		conn.alive(addr)
+		s.rateLimited(conn, resp, packet.Request, LimitScopeConnection)
+		return
+	}
+
+	//Установка/проверка подключения, первый пакет нового
+	//подключения тоже учитывается в его ограничении
+	created := conn == nil
+	conn = s.setConnection(listener, addr, packet)
+	if created {
+		conn.limiter.Allow()
+	}
+	conn.stats.add()
 
 	//Проверяем события
 	switch packet.Header.Event {
 	//Отправляем команду о подключении клиенту
 	case int(protocol.EventConnected):
 		//отправляем клиенту ответ
-		go conn.Send4(int(protocol.EventConnected))
+		conn.Send4(int(protocol.EventConnected))
 		return
 	//Команда на отключение клиента
 	case int(protocol.EventDisconnect):
@@ -200,21 +407,27 @@ func (s *Server) do(addr *net.UDPAddr, packet *protocol.Packet) {
 		conn.Connected.Set(false)
 		conn.disconnect()
 		return
+	//Клиент проверяет, что сервер отвечает
+	case int(protocol.EventCheckConnection):
+		conn.Send4(int(protocol.EventCheckConnection))
+		return
 	}
 
 	if packet.Request != nil {
 		//Если есть данные с прицепом, то что то с ними делаем...
-		go s.handleFuncRoute(conn, resp, *packet.Request)
+		s.handleFuncRoute(conn, resp, *packet.Request)
 	}
 }
 
-func (s *Server) setConnection(addr *net.UDPAddr, packet *protocol.Packet) (conn *Connection) {
+func (s *Server) setConnection(listener net.PacketConn, addr net.Addr, packet *protocol.Packet) (conn *Connection) {
 	//Возвращаем подключение по имени компа
 	v, ok := s.Connections.Load(packet.Header.Hostname)
 	if !ok {
 		//Создаем и добавляем подключение
-		conn = s.newConnection(addr, packet.Header)
+		conn = s.newConnection(listener, addr, packet.Header)
 		s.Connections.Store(packet.Header.Hostname, conn)
+		s.saveConnection(conn)
+		s.audit(audit.KindConnected, conn, nil, "")
 		//событие подключения клиента
 		OnConnected(s.Handler, conn)
 		packet.Header.Event = int(protocol.EventConnected)
@@ -222,10 +435,14 @@ func (s *Server) setConnection(addr *net.UDPAddr, packet *protocol.Packet) (conn
 	}
 	//Приводим значение из списка к Connection
 	conn = v.(*Connection)
+	//Восстановленное подключение дождалось клиента
+	conn.Pending.Set(false)
 
 	//Если пришли немного отличающиеся данные,
 	//то обновляем данные по подключению
-	if conn.updated(addr, packet.Header) {
+	if changes := conn.updated(listener, addr, packet.Header); len(changes) > 0 {
+		s.saveConnection(conn)
+		s.audit(audit.KindReconnected, conn, changes, "")
 		//событие переподключения клиента
 		OnReconnected(s.Handler, conn)
 		packet.Header.Event = int(protocol.EventConnected)
@@ -236,6 +453,21 @@ func (s *Server) setConnection(addr *net.UDPAddr, packet *protocol.Packet) (conn
 	return conn
 }
 
+//Отвечаем клиенту, что запрос отклонен из-за превышения ограничения.
+//c - nil, если клиент еще не подключен, ему не отвечаем
+func (s *Server) rateLimited(c *Connection, resp protocol.IResponse, req *protocol.Request, scope LimitScope) {
+	OnRateLimited(s.Handler, c, scope, req)
+	if c == nil || req == nil {
+		return
+	}
+	resp.SetError(protocol.NewError(protocol.StatusCodeRateLimited, "Превышено ограничение количества запросов").
+		WithDetail("scope", scope.String()))
+	_, _ = c.Send(resp)
+	if s.LogLevel == LogLevelHigh {
+		s.Printf("rateLimited: %s, %s\n", c.Hostname, scope.String())
+	}
+}
+
 func (s *Server) SetRoute(path string, method protocol.Methods, handler FuncHandler) {
 	s.Router.Store(fmt.Sprintf("%s:%d", path, method), &Route{
 		Path:    path,
@@ -244,16 +476,74 @@ func (s *Server) SetRoute(path string, method protocol.Methods, handler FuncHand
 	})
 }
 
+//Устанавливаем ограничение количества запросов для маршрута
+func (s *Server) SetRouteLimit(path string, method protocol.Methods, limit Limit) error {
+	v, ok := s.Router.Load(fmt.Sprintf("%s:%d", path, method))
+	if !ok {
+		return errors.New(fmt.Sprintf("Маршрут [%s] не найден", path))
+	}
+	route := v.(*Route)
+	s.Router.Store(fmt.Sprintf("%s:%d", path, method), &Route{
+		Path:    route.Path,
+		Method:  route.Method,
+		Handler: route.Handler,
+		Limit:   limit,
+		limiter: NewBucket(limit),
+	})
+	return nil
+}
+
 func (s *Server) handleFuncRoute(c *Connection, resp protocol.IResponse, req protocol.Request) {
 	v, ok := s.Router.Load(fmt.Sprintf("%s:%d", req.Path, req.Method))
-	if ok {
-		route := v.(*Route)
-		if route.Method != req.Method {
-			resp.SetData(protocol.StatusCodeError, []rune(fmt.Sprintf("Метод запроса не соответствует маршруту [%s]", route.Path)))
-			return
+	if !ok {
+		_, _ = c.Send(resp.SetError(s.routeNotFound(req)))
+		return
+	}
+	route := v.(*Route)
+	if !route.limiter.Allow() {
+		s.rateLimited(c, resp, &req, LimitScopeRoute)
+		return
+	}
+	//Сервер останавливается - новые запросы не принимаем
+	if !s.inFlight.Add() {
+		_, _ = c.Send(resp.SetError(protocol.NewError(protocol.StatusCodeUnavailable, "Сервер останавливается")))
+		return
+	}
+	//Обработчик выполняется вне пула, чтобы медленные обработчики
+	//не задерживали разбор пакетов и проверки подключения
+	select {
+	case s.handlers <- struct{}{}:
+	default:
+		s.inFlight.Done()
+		_, _ = c.Send(resp.SetError(protocol.NewError(protocol.StatusCodeUnavailable, "Превышено количество одновременных запросов")))
+		return
+	}
+	span := s.traceHandler(c, &req)
+	go func() {
+		defer func() {
+			<-s.handlers
+		}()
+		defer s.inFlight.Done()
+		defer span.Finish()
+		s.wrap(route.Handler)(c, resp, req)
+	}()
+}
+
+//Маршрут не найден. Если путь есть, но с другим методом - StatusCodeMethodNotAllowed
+func (s *Server) routeNotFound(req protocol.Request) *protocol.Error {
+	var methods []string
+	s.Router.Range(func(key, value interface{}) bool {
+		route := value.(*Route)
+		if route.Path == req.Path {
+			methods = append(methods, route.Method.String())
 		}
-		go route.Handler(c, resp, req)
+		return true
+	})
+	if len(methods) > 0 {
+		return protocol.Errorf(protocol.StatusCodeMethodNotAllowed, "Метод %s не поддерживается маршрутом [%s]", req.Method.String(), req.Path).
+			WithDetail("allow", strings.Join(methods, ","))
 	}
+	return protocol.Errorf(protocol.StatusCodeNotFound, "Маршрут [%s] не найден", req.Path)
 }
 
 func (s *Server) Send(hostname string, response *protocol.Response) (n int, err error) {
@@ -269,16 +559,44 @@ func (s *Server) Send(hostname string, response *protocol.Response) (n int, err
 	return connection.Send(response)
 }
 
+//Принудительно отключаем клиента
+func (s *Server) Disconnect(hostname string) error {
+	v, ok := s.Connections.Load(strings.ToUpper(hostname))
+	if !ok {
+		return errors.New(fmt.Sprintf("host: %s - подключение отсутствует!", hostname))
+	}
+	conn := v.(*Connection)
+	conn.Connected.Set(false)
+	conn.disconnect()
+	return nil
+}
+
 func (s *Server) SendByLogin(login string, response *protocol.Response) (n int) {
-	//Ищем по логину тачки
+	//Ищем по логину тачки, ответ для каждой готовим так же как в Send.
+	//Пакеты группируем по сокету подключения, как и ответы в Send
+	datagrams := map[net.PacketConn][]datagram{}
 	s.Connections.Range(func(key, value interface{}) bool {
 		connection := value.(*Connection)
-		if connection.Login == strings.ToLower(login) {
-			_, _ = connection.Send(response)
-			n++
+		if connection.Login != strings.ToLower(login) {
+			return true
+		}
+		b, err := connection.marshal(response)
+		if err != nil {
+			s.Printf("SendByLogin: %s: %v\n", connection.Hostname, err)
+			return true
+		}
+		if b != nil {
+			datagrams[connection.listener] = append(datagrams[connection.listener], datagram{b: b, addr: connection.IpAddress})
 		}
 		return true
 	})
+	for listener, d := range datagrams {
+		sent, err := s.writeBatch(listener, d)
+		if err != nil {
+			s.Printf("SendByLogin: %v\n", err)
+		}
+		n += sent
+	}
 	return
 }
 
@@ -300,6 +618,26 @@ func (s *Server) GetRoutes() (routes map[string]*Route) {
 	return
 }
 
+//Адрес на котором сервер принимает пакеты, nil - сервер не запущен.
+//Нужен если сервер запущен на порту 0
+func (s *Server) LocalAddr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.LocalAddr()
+}
+
+func (s *Server) IsStarted() bool {
+	return s.Started.Get()
+}
+
+func (s *Server) PoolMetrics() pool.Metrics {
+	if s.pool == nil {
+		return pool.Metrics{}
+	}
+	return s.pool.Metrics()
+}
+
 func (s *Server) OnStart(handler HandleServer) {
 	s.Handler.OnStart = handler
 }
@@ -316,13 +654,75 @@ func (s *Server) OnDisconnected(handler HandleConnection) {
 	s.Handler.OnDisconnected = handler
 }
 
+func (s *Server) OnRateLimited(handler HandleRateLimited) {
+	s.Handler.OnRateLimited = handler
+}
+
+//OnStop вызывается один раз, даже если Stop вызван после Shutdown
 func (s *Server) Stop() error {
+	s.stopOnce.Do(func() {
+		OnStop(s.Handler, s)
+	})
+	return s.stop()
+}
+
+func (s *Server) stop() (err error) {
 	//defer s.listener.Close()
-	OnStop(s.Handler, s)
+	s.inFlight.Close()
+	s.cancel()
 	s.Started.Set(false)
 	for _, conn := range s.GetConnections() {
+		//Подключения остаются в хранилище и
+		//восстановятся при следующем запуске
+		if s.Store != nil {
+			conn.timer.Stop()
+			continue
+		}
 		conn.Connected.Set(false)
 		conn.disconnect()
 	}
-	return s.listener.Close()
+	s.pool.Stop()
+	if s.Store != nil {
+		if e := s.Store.Close(); e != nil {
+			err = e
+		}
+	}
+	//Журнал закрываем после отключения клиентов,
+	//чтобы события отключения в него попали
+	if s.Audit != nil {
+		if e := s.Audit.Close(); e != nil {
+			err = e
+		}
+	}
+	if e := s.stopDiscovery(); e != nil {
+		err = e
+	}
+	for _, listener := range s.listeners {
+		if e := listener.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//Пакет для отправки адресату
+type datagram struct {
+	b    []byte
+	addr net.Addr
+}
+
+//Отправка пакетов по одному
+//Отправка пакетов по одному. Ошибка не прерывает отправку
+//остальных пакетов, возвращается первая ошибка
+func (s *Server) writeEach(listener net.PacketConn, datagrams []datagram) (n int, err error) {
+	for _, d := range datagrams {
+		if _, e := listener.WriteTo(d.b, d.addr); e != nil {
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		n++
+	}
+	return
 }
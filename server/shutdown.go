@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrShutdownTimeout = errors.New("Вышло время ожидания завершения обработчиков")
+
+//Счетчик выполняющихся обработчиков маршрутов
+type InFlight struct {
+	sync.Mutex
+	count  int
+	closed bool
+	//Закрывается, когда прием закрыт и обработчиков не осталось.
+	//Один канал на все вызовы Wait, поэтому ожидание по ctx
+	//не оставляет висящих горутин
+	done chan struct{}
+}
+
+//Регистрируем новый обработчик, false - прием новых запросов закрыт
+func (f *InFlight) Add() bool {
+	f.Lock()
+	defer f.Unlock()
+	if f.closed {
+		return false
+	}
+	f.count++
+	return true
+}
+
+func (f *InFlight) Done() {
+	f.Lock()
+	f.count--
+	f.release()
+	f.Unlock()
+}
+
+//Прием новых запросов закрыт
+func (f *InFlight) Closed() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.closed
+}
+
+func (f *InFlight) Open() {
+	f.Lock()
+	f.closed = false
+	f.done = nil
+	f.Unlock()
+}
+
+//Закрываем прием новых запросов
+func (f *InFlight) Close() {
+	f.Lock()
+	f.closed = true
+	f.release()
+	f.Unlock()
+}
+
+func (f *InFlight) release() {
+	if !f.closed || f.count > 0 {
+		return
+	}
+	if f.done == nil {
+		f.done = make(chan struct{})
+	}
+	select {
+	case <-f.done:
+	default:
+		close(f.done)
+	}
+}
+
+//Ждем завершения обработчиков после Close, но не дольше чем позволяет ctx
+func (f *InFlight) Wait(ctx context.Context) error {
+	f.Lock()
+	if f.done == nil {
+		f.done = make(chan struct{})
+	}
+	done := f.done
+	f.Unlock()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrShutdownTimeout, ctx.Err())
+	}
+}
+
+//Плавная остановка сервера. Перестаем принимать новые запросы и
+//подключения, ждем завершения обработчиков маршрутов и разбора пакетов,
+//которые уже в очереди пула, - их ответы уходят до закрытия сокета.
+//Все это не дольше чем позволяет ctx, после чего, как и Stop,
+//уведомляем клиентов событием отключения и закрываем сокет
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		OnStop(s.Handler, s)
+	})
+	s.inFlight.Close()
+	err := s.inFlight.Wait(ctx)
+	if err == nil {
+		s.pool.Stop()
+		select {
+		case <-s.pool.Done():
+		case <-ctx.Done():
+			err = fmt.Errorf("%w: %v", ErrShutdownTimeout, ctx.Err())
+		}
+	}
+	if e := s.stop(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+//Сервер с маршрутом slow: обработчик сообщает о запуске
+//и отвечает только после сигнала release
+func startSlow(t *testing.T) (srv *Server, started, release chan struct{}) {
+	started = make(chan struct{}, 1)
+	release = make(chan struct{})
+	srv = New(Config{Host: "127.0.0.1", BufferSize: 1024, DisconnectTimeout: 30}).(*Server)
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("slow", protocol.MethodGet, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		started <- struct{}{}
+		<-release
+		c.Send1(resp.SetData(protocol.StatusCodeOK, protocol.ToRunes("done")))
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Stop()
+	})
+	return srv, started, release
+}
+
+func startClient(t *testing.T, srv *Server) (*client.Client, chan bool) {
+	clt := client.New(client.Config{
+		Host:       "127.0.0.1",
+		Port:       srv.LocalAddr().(*net.UDPAddr).Port,
+		BufferSize: 1024,
+		Timeout:    3,
+	}).(*client.Client)
+	clt.SetLogger(ioutil.Discard, "", 0)
+	connected := make(chan bool, 1)
+	disconnected := make(chan bool, 4)
+	clt.OnConnected(func(c *client.Client) {
+		connected <- true
+	})
+	clt.OnDisconnected(func(c *client.Client) {
+		disconnected <- true
+	})
+	if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clt.Stop)
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("клиент не подключился")
+	}
+	return clt, disconnected
+}
+
+//Запрос к slow в фоне, результат в канале
+func sendSlow(clt *client.Client) chan *protocol.Response {
+	result := make(chan *protocol.Response, 1)
+	go func() {
+		resp, _ := clt.Send(protocol.NewRequest("slow", protocol.MethodGet))
+		result <- resp
+	}()
+	return result
+}
+
+//Shutdown ждет обработчик, клиент получает ответ, а затем событие отключения
+func TestShutdownDrain(t *testing.T) {
+	srv, started, release := startSlow(t)
+	clt, disconnected := startClient(t, srv)
+	result := sendSlow(clt)
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- srv.Shutdown(context.Background())
+	}()
+	//Пока обработчик выполняется, клиента не отключают
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown не дождался обработчика: %v", err)
+	case <-disconnected:
+		t.Fatal("событие отключения до завершения обработчика")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case resp := <-result:
+		if resp == nil || resp.Data.String() != "done" {
+			t.Fatalf("ответ: %v", resp)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("нет ответа")
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("клиент не получил событие отключения")
+	}
+	if len(srv.GetConnections()) != 0 {
+		t.Errorf("подключения: %v", srv.GetConnections())
+	}
+	//Отключение отправляется один раз
+	select {
+	case <-disconnected:
+		t.Error("повторное событие отключения")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	srv, started, release := startSlow(t)
+	defer close(release)
+	clt, disconnected := startClient(t, srv)
+	sendSlow(clt)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := srv.Shutdown(ctx)
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Shutdown: %v", d)
+	}
+	//Клиентов отключают и без завершения обработчиков
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("клиент не получил событие отключения")
+	}
+}
+
+//Во время ожидания обработчиков новые клиенты не подключаются
+func TestShutdownRejectConnections(t *testing.T) {
+	srv, started, release := startSlow(t)
+	clt, _ := startClient(t, srv)
+	sendSlow(clt)
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- srv.Shutdown(context.Background())
+	}()
+	for !srv.inFlight.Closed() {
+		time.Sleep(time.Millisecond)
+	}
+	if resp := sendRaw(t, dialRaw(t, srv), "pc-2", protocol.EventConnected, nil); resp != nil {
+		t.Errorf("ответ новому клиенту: %v", resp)
+	}
+	if _, ok := srv.Connections.Load("PC-2"); ok {
+		t.Error("подключение создано во время остановки")
+	}
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+}
+
+//Stop после Shutdown не вызывает OnStop повторно
+func TestShutdownOnStopOnce(t *testing.T) {
+	srv, _, _ := startSlow(t)
+	stopped := make(chan bool, 2)
+	srv.OnStop(func(s *Server) {
+		stopped <- true
+	})
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = srv.Stop()
+	<-stopped
+	select {
+	case <-stopped:
+		t.Error("повторный вызов OnStop")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+//Ожидание по ctx не оставляет горутин
+func TestInFlightWait(t *testing.T) {
+	var f InFlight
+	if !f.Add() {
+		t.Fatal("Add")
+	}
+	f.Close()
+	if f.Add() {
+		t.Fatal("Add после Close")
+	}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		if err := f.Wait(ctx); !errors.Is(err, ErrShutdownTimeout) {
+			t.Fatalf("Wait: %v", err)
+		}
+		cancel()
+	}
+	if n := runtime.NumGoroutine(); n > before+5 {
+		t.Errorf("горутин: %d, было %d", n, before)
+	}
+	f.Done()
+	if err := f.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//После закрытия приема новые запросы отклоняются
+func TestShutdownReject(t *testing.T) {
+	srv, _, release := startSlow(t)
+	defer close(release)
+	clt, _ := startClient(t, srv)
+	srv.inFlight.Close()
+	resp, err := clt.Send(protocol.NewRequest("slow", protocol.MethodGet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(resp.Err(), protocol.ErrUnavailable) {
+		t.Fatalf("ответ: %v", resp)
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/egovorukhin/egoudp/transport"
+)
+
+//Сохраненные данные подключения, по ним сервер
+//восстанавливает подключения после перезапуска
+type Session struct {
+	Hostname    string    `json:"hostname"`
+	Network     string    `json:"network,omitempty"`
+	IpAddress   string    `json:"ip_address"`
+	Domain      string    `json:"domain"`
+	Login       string    `json:"login"`
+	Version     string    `json:"version"`
+	ConnectTime time.Time `json:"connect_time"`
+}
+
+//Хранилище подключений. Сервер сохраняет подключение при подключении
+//и изменении данных, удаляет при отключении
+type ConnectionStore interface {
+	Load() ([]Session, error)
+	Save(session Session) error
+	Delete(hostname string) error
+	Close() error
+}
+
+//Хранилище в памяти, переживает перезапуск сервера в рамках процесса
+type MemoryStore struct {
+	sessions sync.Map
+}
+
+func NewMemoryStore() *MemoryStore {
+	return new(MemoryStore)
+}
+
+func (m *MemoryStore) Load() (sessions []Session, err error) {
+	m.sessions.Range(func(key, value interface{}) bool {
+		sessions = append(sessions, value.(Session))
+		return true
+	})
+	return
+}
+
+func (m *MemoryStore) Save(session Session) error {
+	m.sessions.Store(session.Hostname, session)
+	return nil
+}
+
+func (m *MemoryStore) Delete(hostname string) error {
+	m.sessions.Delete(hostname)
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+//Хранилище в файле, снимок всех подключений в json.
+//Файл перезаписывается целиком при каждом изменении
+type FileStore struct {
+	sync.Mutex
+	path     string
+	sessions map[string]Session
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path:     path,
+		sessions: map[string]Session{},
+	}
+}
+
+//Отсутствующий файл - пустое хранилище
+func (f *FileStore) Load() ([]Session, error) {
+	f.Lock()
+	defer f.Unlock()
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sessions []Session
+	err = json.Unmarshal(b, &sessions)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Ошибка чтения хранилища %s: %v", f.path, err))
+	}
+	f.sessions = map[string]Session{}
+	for _, session := range sessions {
+		f.sessions[session.Hostname] = session
+	}
+	return sessions, nil
+}
+
+func (f *FileStore) Save(session Session) error {
+	f.Lock()
+	defer f.Unlock()
+	f.sessions[session.Hostname] = session
+	return f.write()
+}
+
+func (f *FileStore) Delete(hostname string) error {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.sessions[hostname]; !ok {
+		return nil
+	}
+	delete(f.sessions, hostname)
+	return f.write()
+}
+
+func (f *FileStore) Close() error {
+	return nil
+}
+
+//Пишем во временный файл и переименовываем,
+//чтобы при падении не остался недописанный снимок
+func (f *FileStore) write() error {
+	sessions := make([]Session, 0, len(f.sessions))
+	for _, session := range f.sessions {
+		sessions = append(sessions, session)
+	}
+	b, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (c *Connection) session() Session {
+	return Session{
+		Hostname:    c.Hostname,
+		Network:     c.IpAddress.Network(),
+		IpAddress:   c.IpAddress.String(),
+		Domain:      c.Domain,
+		Login:       c.Login,
+		Version:     c.Version,
+		ConnectTime: c.ConnectTime,
+	}
+}
+
+//Восстанавливаем подключения из хранилища. Подключение ожидает
+//первого пакета от клиента, если пакет не придет за DisconnectTimeout,
+//то подключение будет удалено
+func (s *Server) restoreConnections() error {
+	if s.Store == nil {
+		return nil
+	}
+	sessions, err := s.Store.Load()
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		addr, err := transport.ResolveAddr(session.Network, session.IpAddress)
+		if err != nil {
+			s.Printf("restore: %s: %v\n", session.Hostname, err)
+			_ = s.Store.Delete(session.Hostname)
+			continue
+		}
+		conn := &Connection{
+			Server:      s,
+			listener:    s.listener,
+			Hostname:    session.Hostname,
+			IpAddress:   addr,
+			Family:      transport.Family(addr),
+			Domain:      session.Domain,
+			Login:       session.Login,
+			ConnectTime: session.ConnectTime,
+			Version:     session.Version,
+			limiter:     NewBucket(s.RateLimit.Connection),
+			Pending: Connected{
+				value: true,
+			},
+		}
+		conn.startDTimer(s.DisconnectTimeout)
+		s.Connections.Store(conn.Hostname, conn)
+	}
+	return nil
+}
+
+func (s *Server) saveConnection(c *Connection) {
+	if s.Store == nil {
+		return
+	}
+	err := s.Store.Save(c.session())
+	if err != nil {
+		s.Printf("store: %s: %v\n", c.Hostname, err)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "connections.json")
+	store := NewFileStore(path)
+	sessions, err := store.Load()
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("Load: %v, %v", sessions, err)
+	}
+	session := Session{
+		Hostname:    "PC-1",
+		IpAddress:   "127.0.0.1:5000",
+		Login:       "user",
+		ConnectTime: time.Now().Truncate(time.Second),
+	}
+	_ = store.Save(session)
+	_ = store.Save(Session{Hostname: "PC-2", IpAddress: "127.0.0.1:5001"})
+	_ = store.Delete("PC-2")
+
+	sessions, err = NewFileStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].Hostname != "PC-1" || !sessions[0].ConnectTime.Equal(session.ConnectTime) {
+		t.Errorf("sessions: %v", sessions)
+	}
+}
+
+//Подключение переживает перезапуск сервера: после запуска оно ожидает
+//клиента, а первый пакет не вызывает повторного OnConnected
+func TestRestoreConnections(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "connections.json"))
+	start := func() *Server {
+		srv := New(Config{BufferSize: 1024, DisconnectTimeout: 30, Store: store}).(*Server)
+		srv.SetLogger(ioutil.Discard, "", 0)
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+		return srv
+	}
+	stop := func(srv *Server) {
+		_ = srv.Stop()
+	}
+
+	conn, err := net.ListenUDP(udp, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	packet := protocol.New("pc-1", "user", "hq", "1.0.0").Marshal()
+	send := func(srv *Server) {
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: srv.listener.LocalAddr().(*net.UDPAddr).Port}
+		if _, err := conn.WriteToUDP(packet, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait := func(cond func() bool) bool {
+		for i := 0; i < 100; i++ {
+			if cond() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	srv := start()
+	send(srv)
+	if !wait(func() bool { return len(srv.GetConnections()) == 1 }) {
+		t.Fatal("подключение не создано")
+	}
+	connectTime := srv.GetConnections()["PC-1"].ConnectTime
+	stop(srv)
+
+	var connected int32
+	srv = start()
+	defer stop(srv)
+	srv.OnConnected(func(c *Connection) {
+		atomic.AddInt32(&connected, 1)
+	})
+	c, ok := srv.GetConnections()["PC-1"]
+	if !ok {
+		t.Fatal("подключение не восстановлено")
+	}
+	if !c.Pending.Get() || !c.ConnectTime.Equal(connectTime) || c.Login != "user" {
+		t.Errorf("restored: %s", c)
+	}
+
+	send(srv)
+	if !wait(func() bool { return !c.Pending.Get() }) {
+		t.Fatal("подключение осталось в ожидании")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt32(&connected); n != 0 {
+		t.Errorf("OnConnected: %d", n)
+	}
+}
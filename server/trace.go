@@ -0,0 +1,43 @@
+package server
+
+import (
+	"time"
+
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/trace"
+)
+
+//Спан разбора пакета от start до текущего момента и начало спана
+//обработки запроса. Контекст обработки передается дальше в метаданных запроса
+func (s *Server) traceParse(req *protocol.Request, start time.Time, err error) *trace.Span {
+	if s.Tracer == nil {
+		return nil
+	}
+	parent := trace.Extract(req.Metadata)
+
+	span := s.Tracer.StartAt("server.parse", parent, start)
+	span.SetAttribute("path", req.Path)
+	span.SetError(err)
+	span.Finish()
+	if err != nil {
+		return nil
+	}
+
+	dispatch := s.Tracer.Start("server.dispatch", parent)
+	dispatch.SetAttribute("path", req.Path)
+	dispatch.SetAttribute("method", req.Method.String())
+	req.Metadata = trace.Inject(req.Metadata, dispatch.SpanContext())
+	return dispatch
+}
+
+//Спан выполнения обработчика маршрута
+func (s *Server) traceHandler(c *Connection, req *protocol.Request) *trace.Span {
+	if s.Tracer == nil {
+		return nil
+	}
+	span := s.Tracer.Start("server.handler "+req.Path, trace.Extract(req.Metadata))
+	span.SetAttribute("hostname", c.Hostname)
+	span.SetAttribute("method", req.Method.String())
+	req.Metadata = trace.Inject(req.Metadata, span.SpanContext())
+	return span
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/trace"
+)
+
+//Типизированный обработчик маршрута. ctx отменяется при остановке сервера
+//и содержит контекст трассировки обработчика
+type TypedHandler[Req, Resp any] func(ctx context.Context, c *Connection, req Req) (Resp, error)
+
+//Ошибка обработчика может определить код статуса ответа,
+//иначе клиенту возвращается StatusCodeError. *protocol.Error
+//передается клиенту целиком, вместе с деталями
+type StatusCoder interface {
+	StatusCode() protocol.StatusCode
+}
+
+//Устанавливаем типизированный маршрут. Данные запроса декодируются в Req,
+//а результат кодируется кодеком по ContentType запроса, по умолчанию json
+func Handle[Req, Resp any](srv IServer, path string, method protocol.Methods, handler TypedHandler[Req, Resp]) {
+	srv.SetRoute(path, method, func(c *Connection, resp protocol.IResponse, req protocol.Request) {
+		if req.ContentType == "" {
+			req.ContentType = protocol.ContentTypeJSON
+		}
+		var in Req
+		if len(req.Data) > 0 {
+			err := req.Bind(&in)
+			if err != nil {
+				c.sendError(resp, err)
+				return
+			}
+		}
+		ctx := trace.ContextWithSpanContext(c.ctx, trace.Extract(req.Metadata))
+		out, err := handler(ctx, c, in)
+		if err != nil {
+			c.sendError(resp, err)
+			return
+		}
+		r, err := resp.Encode(req.ContentType, out)
+		if err != nil {
+			c.sendError(resp, err)
+			return
+		}
+		_, err = c.Send(r)
+		if err != nil {
+			c.Printf("Handle: %v\n", err)
+		}
+	})
+}
+
+func (c *Connection) sendError(resp protocol.IResponse, err error) {
+	var e *protocol.Error
+	if !errors.As(err, &e) {
+		code := protocol.StatusCodeError
+		var coder StatusCoder
+		if errors.As(err, &coder) {
+			code = coder.StatusCode()
+		}
+		e = protocol.NewError(code, err.Error())
+	}
+	_, err = c.Send(resp.SetError(e))
+	if err != nil {
+		c.Printf("sendError: %v\n", err)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+)
+
+type seasonRequest struct {
+	Month string `json:"month"`
+}
+
+type seasonResponse struct {
+	Season string `json:"season"`
+}
+
+//Ошибка с собственным кодом статуса
+type conflict string
+
+func (e conflict) Error() string {
+	return string(e)
+}
+
+func (e conflict) StatusCode() protocol.StatusCode {
+	return protocol.StatusCodeConflict
+}
+
+func startTyped(t *testing.T) *client.Client {
+	srv := startHost(t, "", "127.0.0.1")
+	Handle(srv, "season", protocol.MethodGet, func(ctx context.Context, c *Connection, req seasonRequest) (seasonResponse, error) {
+		switch req.Month {
+		case "Январь":
+			return seasonResponse{Season: "Зима"}, nil
+		case "Июль":
+			return seasonResponse{Season: "Лето"}, conflict("Лето занято")
+		case "":
+			return seasonResponse{}, protocol.NewError(protocol.StatusCodeBadRequest, "Месяц не указан").WithDetail("field", "month")
+		}
+		return seasonResponse{}, errors.New("Неизвестный месяц")
+	})
+	//Результат, который не кодируется в json
+	Handle(srv, "broken", protocol.MethodGet, func(ctx context.Context, c *Connection, req seasonRequest) (chan int, error) {
+		return make(chan int), nil
+	})
+	clt, _ := startClient(t, srv)
+	return clt
+}
+
+func TestTypedRoundTrip(t *testing.T) {
+	clt := startTyped(t)
+	resp, err := client.Call[seasonRequest, seasonResponse](context.Background(), clt, "season", protocol.MethodGet, seasonRequest{Month: "Январь"})
+	if err != nil || resp.Season != "Зима" {
+		t.Fatalf("ответ: %v, %v", resp, err)
+	}
+}
+
+//Ошибка обработчика возвращается клиенту с кодом статуса
+func TestTypedErrorStatus(t *testing.T) {
+	clt := startTyped(t)
+	for _, tt := range []struct {
+		month  string
+		code   protocol.StatusCode
+		detail string
+	}{
+		{"Июль", protocol.StatusCodeConflict, ""},
+		{"", protocol.StatusCodeBadRequest, "month"},
+		{"Брюмер", protocol.StatusCodeError, ""},
+	} {
+		_, err := client.Call[seasonRequest, seasonResponse](context.Background(), clt, "season", protocol.MethodGet, seasonRequest{Month: tt.month})
+		var e *protocol.Error
+		if !errors.As(err, &e) || e.Code != tt.code || e.Details["field"] != tt.detail {
+			t.Errorf("%q: %v", tt.month, err)
+		}
+	}
+}
+
+func TestTypedDecodeError(t *testing.T) {
+	clt := startTyped(t)
+	//Сервер не может декодировать запрос
+	_, err := client.Call[[]int, seasonResponse](context.Background(), clt, "season", protocol.MethodGet, []int{1})
+	var e *protocol.Error
+	if !errors.As(err, &e) || e.Code != protocol.StatusCodeError {
+		t.Errorf("запрос: %v", err)
+	}
+	//Клиент не может декодировать ответ
+	_, err = client.Call[seasonRequest, []int](context.Background(), clt, "season", protocol.MethodGet, seasonRequest{Month: "Январь"})
+	if err == nil || errors.As(err, &e) {
+		t.Errorf("ответ: %v", err)
+	}
+}
+
+func TestTypedEncodeError(t *testing.T) {
+	clt := startTyped(t)
+	//Сервер не может закодировать ответ
+	_, err := client.Call[seasonRequest, seasonResponse](context.Background(), clt, "broken", protocol.MethodGet, seasonRequest{})
+	var e *protocol.Error
+	if !errors.As(err, &e) || e.Code != protocol.StatusCodeError {
+		t.Errorf("ответ: %v", err)
+	}
+	//Клиент не может закодировать запрос, запрос не отправляется
+	_, err = client.Call[chan int, seasonResponse](context.Background(), clt, "season", protocol.MethodGet, make(chan int))
+	if err == nil || errors.As(err, &e) {
+		t.Errorf("запрос: %v", err)
+	}
+}
+
+//Запрос с другим кодеком через CallAs
+func TestTypedCallAs(t *testing.T) {
+	clt := startTyped(t)
+	for _, contentType := range []string{protocol.ContentTypeJSON, protocol.ContentTypeXML, protocol.ContentTypeMsgpack} {
+		resp, err := client.CallAs[seasonRequest, seasonResponse](context.Background(), clt, "season", protocol.MethodGet, contentType, seasonRequest{Month: "Январь"})
+		if err != nil || resp.Season != "Зима" {
+			t.Errorf("%s: %v, %v", contentType, resp, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.CallAs[seasonRequest, seasonResponse](ctx, clt, "season", protocol.MethodGet, protocol.ContentTypeJSON, seasonRequest{Month: "Январь"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx: %v", err)
+	}
+}
+
+//Ожидание ответа прерывается по ctx раньше Timeout клиента
+func TestTypedCallAsDeadline(t *testing.T) {
+	srv, _, release := startSlow(t)
+	defer close(release)
+	clt, _ := startClient(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.CallAs[seasonRequest, seasonResponse](ctx, clt, "slow", protocol.MethodGet, protocol.ContentTypeJSON, seasonRequest{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ctx: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("CallAs: %v", d)
+	}
+}
@@ -0,0 +1,43 @@
+package trace
+
+import "sync"
+
+//Хранение завершенных спанов в памяти, для тестов
+type Memory struct {
+	sync.Mutex
+	spans []*Span
+}
+
+func NewMemory() *Memory {
+	return new(Memory)
+}
+
+func (m *Memory) Export(span *Span) {
+	m.Lock()
+	m.spans = append(m.spans, span)
+	m.Unlock()
+}
+
+func (m *Memory) Spans() []*Span {
+	m.Lock()
+	defer m.Unlock()
+	spans := make([]*Span, len(m.spans))
+	copy(spans, m.spans)
+	return spans
+}
+
+//Спаны трассы в порядке завершения
+func (m *Memory) Trace(id TraceID) (spans []*Span) {
+	for _, span := range m.Spans() {
+		if span.Context.TraceID == id {
+			spans = append(spans, span)
+		}
+	}
+	return
+}
+
+func (m *Memory) Reset() {
+	m.Lock()
+	m.spans = nil
+	m.Unlock()
+}
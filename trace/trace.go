@@ -0,0 +1,230 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Ключ метаданных запроса с контекстом трассировки в формате W3C traceparent
+const MetadataKey = "traceparent"
+
+const version = "00"
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+//Контекст трассировки, передается между клиентом и сервером
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+//Формат traceparent: 00-<trace-id>-<span-id>-<flags>
+func (sc SpanContext) String() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", version, sc.TraceID.String(), sc.SpanID.String(), sc.Flags)
+}
+
+func Parse(s string) (sc SpanContext, err error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || parts[0] != version ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errors.New(fmt.Sprintf("Неверный формат traceparent - %s", s))
+	}
+	_, err = hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	if err != nil {
+		return
+	}
+	_, err = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	if err != nil {
+		return
+	}
+	var flags [1]byte
+	_, err = hex.Decode(flags[:], []byte(parts[3]))
+	if err != nil {
+		return
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errors.New(fmt.Sprintf("Нулевой идентификатор в traceparent - %s", s))
+	}
+	return
+}
+
+//Контекст трассировки из метаданных, пустой если его нет или он неверный
+func Extract(metadata map[string]string) SpanContext {
+	sc, _ := Parse(metadata[MetadataKey])
+	return sc
+}
+
+//Возвращаем копию метаданных с контекстом трассировки,
+//исходные метаданные не изменяются
+func Inject(metadata map[string]string, sc SpanContext) map[string]string {
+	if !sc.IsValid() {
+		return metadata
+	}
+	m := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		m[key] = value
+	}
+	m[MetadataKey] = sc.String()
+	return m
+}
+
+type contextKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(contextKey{}).(SpanContext)
+	return sc
+}
+
+//Получатель завершенных спанов. Позволяет передать спаны
+//во внешнюю систему, например в OpenTelemetry
+type Exporter interface {
+	Export(span *Span)
+}
+
+//Трассировщик, nil - трассировка отключена
+type Tracer struct {
+	Exporter Exporter
+}
+
+func New(exporter Exporter) *Tracer {
+	return &Tracer{
+		Exporter: exporter,
+	}
+}
+
+//Начинаем спан, parent - родительский контекст,
+//если он пустой, то начинается новая трасса
+func (t *Tracer) Start(name string, parent SpanContext) *Span {
+	return t.StartAt(name, parent, time.Now())
+}
+
+func (t *Tracer) StartAt(name string, parent SpanContext, start time.Time) *Span {
+	if t == nil {
+		return nil
+	}
+	span := &Span{
+		tracer: t,
+		Name:   name,
+		Start:  start,
+	}
+	if parent.IsValid() {
+		span.Context.TraceID = parent.TraceID
+		span.Context.Flags = parent.Flags
+		span.Parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(span.Context.TraceID[:])
+		span.Context.Flags = 1
+	}
+	_, _ = rand.Read(span.Context.SpanID[:])
+	return span
+}
+
+//Спан - операция в рамках трассы. Методы безопасны для nil
+type Span struct {
+	sync.Mutex
+	tracer     *Tracer
+	Name       string
+	Context    SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Err        error
+	ended      bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.Context
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if s.Attributes == nil {
+		s.Attributes = map[string]string{}
+	}
+	s.Attributes[key] = value
+	s.Unlock()
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Lock()
+	s.Err = err
+	s.Unlock()
+}
+
+//Завершаем спан и передаем его в Exporter, повторный вызов игнорируется
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if s.ended {
+		s.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.Unlock()
+	if s.tracer.Exporter != nil {
+		s.tracer.Exporter.Export(s)
+	}
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func (s *Span) String() string {
+	err := "null"
+	if s.Err != nil {
+		err = s.Err.Error()
+	}
+	return fmt.Sprintf("name: %s, trace: %s, span: %s, parent: %s, duration: %s, attributes: %v, error: %s",
+		s.Name, s.Context.TraceID.String(), s.Context.SpanID.String(), s.Parent.String(), s.Duration(), s.Attributes, err)
+}
@@ -0,0 +1,84 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	s := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.String() != s {
+		t.Errorf("String: %s", sc.String())
+	}
+	if sc.Flags != 1 {
+		t.Errorf("Flags: %d", sc.Flags)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): ожидалась ошибка", s)
+		}
+	}
+}
+
+func TestInject(t *testing.T) {
+	sc, _ := Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	metadata := map[string]string{"login": "user"}
+	m := Inject(metadata, sc)
+	if _, ok := metadata[MetadataKey]; ok {
+		t.Error("Inject изменил исходные метаданные")
+	}
+	if m["login"] != "user" || Extract(m) != sc {
+		t.Errorf("Inject: %v", m)
+	}
+	if Extract(nil).IsValid() {
+		t.Error("Extract(nil)")
+	}
+	ctx := ContextWithSpanContext(context.Background(), sc)
+	if SpanContextFromContext(ctx) != sc {
+		t.Error("SpanContextFromContext")
+	}
+}
+
+func TestTracer(t *testing.T) {
+	spans := NewMemory()
+	tracer := New(spans)
+
+	parent := tracer.Start("client", SpanContext{})
+	child := tracer.Start("server", parent.SpanContext())
+	child.SetError(errors.New("ошибка"))
+	child.Finish()
+	parent.Finish()
+	parent.Finish()
+
+	if child.Context.TraceID != parent.Context.TraceID {
+		t.Error("у дочернего спана другой TraceID")
+	}
+	if child.Parent != parent.Context.SpanID || child.Context.SpanID == parent.Context.SpanID {
+		t.Errorf("parent: %s, child: %s", parent, child)
+	}
+	if n := len(spans.Trace(parent.Context.TraceID)); n != 2 {
+		t.Errorf("spans: %d", n)
+	}
+
+	//Без трассировщика спаны nil и методы не паникуют
+	var nilTracer *Tracer
+	span := nilTracer.Start("nil", SpanContext{})
+	span.SetAttribute("key", "value")
+	span.Finish()
+	if span.SpanContext().IsValid() {
+		t.Error("nil span")
+	}
+}
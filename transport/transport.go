@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const udp = "udp"
+
+//Названия сетей для New. udp - IPv4 и IPv6,
+//udp4 и udp6 - только IPv4 или только IPv6
+const (
+	NetworkUDP      = udp
+	NetworkUDP4     = "udp4"
+	NetworkUDP6     = "udp6"
+	NetworkUnixgram = unixgram
+)
+
+//Транспорт пакетов. Listen открывает сокет сервера на адресе address,
+//Dial - сокет клиента, подключенный к серверу address
+type Transport interface {
+	Listen(address string) (net.PacketConn, error)
+	Dial(address string) (net.Conn, error)
+}
+
+//Транспорт по умолчанию. Network - udp (по умолчанию), udp4 или udp6
+type UDP struct {
+	Network string
+}
+
+func (u UDP) network() string {
+	if u.Network == "" {
+		return udp
+	}
+	return u.Network
+}
+
+//Сокет на адресе без хоста или с хостом 0.0.0.0 или :: для сети udp
+//принимает пакеты по IPv4 и IPv6 (если ОС поддерживает двойной стек)
+func (u UDP) Listen(address string) (net.PacketConn, error) {
+	addr, err := net.ResolveUDPAddr(u.network(), address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP(u.network(), addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (u UDP) Dial(address string) (net.Conn, error) {
+	addr, err := net.ResolveUDPAddr(u.network(), address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP(u.network(), nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+//Транспорт из конфигурации, nil - UDP
+func Get(t Transport) Transport {
+	if t == nil {
+		return UDP{}
+	}
+	return t
+}
+
+//Семейство адреса: ipv4, ipv6 или unix, пустая строка - неизвестно.
+//IPv4 адрес, принятый сокетом с двойным стеком, - ipv4
+func Family(addr net.Addr) string {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.UnixAddr:
+		return FamilyUnix
+	default:
+		return ""
+	}
+	if ip.To4() != nil {
+		return FamilyIPv4
+	}
+	if ip.To16() != nil {
+		return FamilyIPv6
+	}
+	return ""
+}
+
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyUnix = "unix"
+)
+
+//Адрес host:port, IPv6 адрес заключается в квадратные скобки.
+//Хост можно указать и в скобках: [::1]
+func JoinHostPort(host string, port int) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -0,0 +1,157 @@
+package transport_test
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+	"github.com/egovorukhin/egoudp/transport"
+	"github.com/egovorukhin/egoudp/udptest"
+)
+
+func TestUDP(t *testing.T) {
+	srv, err := transport.Get(nil).Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	clt, err := transport.UDP{}.Dial(srv.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clt.Close()
+
+	_, _ = clt.Write([]byte("ping"))
+	buf := make([]byte, 16)
+	_ = srv.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := srv.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "ping" || addr.String() != clt.LocalAddr().String() {
+		t.Fatalf("ReadFrom: %q, %v, %v", buf[:n], addr, err)
+	}
+}
+
+//Шифрование для теста: xor каждого байта
+func xor(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = c ^ 0x5a
+	}
+	return out
+}
+
+type xorPacketConn struct {
+	net.PacketConn
+}
+
+func (c xorPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	copy(b, xor(b[:n]))
+	return n, addr, err
+}
+
+func (c xorPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.PacketConn.WriteTo(xor(b), addr)
+}
+
+type xorConn struct {
+	net.Conn
+}
+
+func (c xorConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	copy(b, xor(b[:n]))
+	return n, err
+}
+
+func (c xorConn) Write(b []byte) (int, error) {
+	return c.Conn.Write(xor(b))
+}
+
+var xorWrapper = transport.Wrapper{
+	PacketConn: func(c net.PacketConn) net.PacketConn {
+		return xorPacketConn{c}
+	},
+	Conn: func(c net.Conn) net.Conn {
+		return xorConn{c}
+	},
+}
+
+func TestWrap(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	srv, err := transport.Wrap(n, xorWrapper).Listen(":5655")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	plain, err := n.Dial(":5655")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+
+	_, _ = plain.Write(xor([]byte("ping")))
+	buf := make([]byte, 16)
+	size, addr, err := srv.ReadFrom(buf)
+	if err != nil || string(buf[:size]) != "ping" {
+		t.Fatalf("ReadFrom: %q, %v", buf[:size], err)
+	}
+	_, _ = srv.WriteTo([]byte("pong"), addr)
+	size, _ = plain.Read(buf)
+	if string(xor(buf[:size])) != "pong" {
+		t.Fatalf("Read: %q", buf[:size])
+	}
+}
+
+//Сервер и клиент с одинаковой оберткой понимают друг друга,
+//а клиент без обертки подключиться не может
+func TestWrapClientServer(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	wrapped := transport.Wrap(n, xorWrapper)
+
+	srv := server.New(server.Config{Port: 5655, BufferSize: 1024, DisconnectTimeout: 30, Transport: wrapped})
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("echo", protocol.MethodGet, func(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	start := func(tr transport.Transport) (client.IClient, chan bool) {
+		clt := client.New(client.Config{Host: "server", Port: 5655, BufferSize: 1024, Timeout: 3, Transport: tr})
+		clt.SetLogger(ioutil.Discard, "", 0)
+		connected := make(chan bool, 1)
+		clt.OnConnected(func(c *client.Client) {
+			connected <- true
+		})
+		if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+			t.Fatal(err)
+		}
+		return clt, connected
+	}
+
+	plain, connected := start(n)
+	select {
+	case <-connected:
+		t.Fatal("клиент без обертки подключился")
+	case <-time.After(100 * time.Millisecond):
+	}
+	plain.Stop()
+
+	clt, connected := start(wrapped)
+	defer clt.Stop()
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("клиент не подключился")
+	}
+	resp, err := clt.Send(protocol.NewRequest("echo", protocol.MethodGet).SetData("text/plain", protocol.ToRunes("привет")))
+	if err != nil || resp.Data.String() != "привет" {
+		t.Fatalf("echo: %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+)
+
+const unixgram = "unixgram"
+
+//Транспорт через Unix datagram сокеты для обмена в пределах одного хоста.
+//Адрес - путь к файлу сокета. Сокет клиента в linux по умолчанию создается
+//в абстрактном пространстве имен (@egoudp-pid-n) и исчезает вместе с процессом,
+//иначе - во временном каталоге в Dir (пусто - os.TempDir()). Сервер видит
+//клиента по этому имени. Файлы сокетов удаляются при закрытии
+type Unixgram struct {
+	Dir string
+}
+
+var dials uint32
+
+//Файл сокета, оставшийся от прошлого запуска, удаляем.
+//Адрес @name - абстрактный сокет linux, файла у него нет
+func (u Unixgram) Listen(address string) (net.PacketConn, error) {
+	if isFile(address) {
+		if fi, err := os.Stat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			err = removeStale(address)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	conn, err := net.ListenUnixgram(unixgram, &net.UnixAddr{Name: address, Net: unixgram})
+	if err != nil {
+		return nil, err
+	}
+	if !isFile(address) {
+		return conn, nil
+	}
+	return &unixPacketConn{UnixConn: conn, path: address}, nil
+}
+
+//Без своего адреса сервер не сможет ответить клиенту,
+//поэтому сокет клиента привязываем к абстрактному имени или временному файлу
+func (u Unixgram) Dial(address string) (net.Conn, error) {
+	raddr := &net.UnixAddr{Name: address, Net: unixgram}
+	if u.Dir == "" && runtime.GOOS == "linux" {
+		name := fmt.Sprintf("@egoudp-%d-%d", os.Getpid(), atomic.AddUint32(&dials, 1))
+		conn, err := net.DialUnix(unixgram, &net.UnixAddr{Name: name, Net: unixgram}, raddr)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	dir, err := ioutil.TempDir(u.Dir, "egoudp")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "client.sock")
+	conn, err := net.DialUnix(unixgram, &net.UnixAddr{Name: path, Net: unixgram}, raddr)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return &unixConn{UnixConn: conn, path: dir}, nil
+}
+
+//Файл удаляем, только если на нем никто не слушает:
+//к сокету работающего сервера подключение проходит
+func removeStale(path string) error {
+	conn, err := net.DialUnix(unixgram, nil, &net.UnixAddr{Name: path, Net: unixgram})
+	if err == nil {
+		_ = conn.Close()
+		return errors.New(fmt.Sprintf("Сокет %s занят другим процессом", path))
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func isFile(address string) bool {
+	return address != "" && address[0] != '@'
+}
+
+type unixPacketConn struct {
+	*net.UnixConn
+	path string
+}
+
+func (c *unixPacketConn) Close() error {
+	err := c.UnixConn.Close()
+	_ = os.Remove(c.path)
+	return err
+}
+
+type unixConn struct {
+	*net.UnixConn
+	path string
+}
+
+func (c *unixConn) Close() error {
+	err := c.UnixConn.Close()
+	_ = os.RemoveAll(c.path)
+	return err
+}
+
+//Транспорт по названию сети: udp (по умолчанию), udp4, udp6 или unixgram
+func New(network string) (Transport, error) {
+	switch network {
+	case "", NetworkUDP, NetworkUDP4, NetworkUDP6:
+		return UDP{Network: network}, nil
+	case NetworkUnixgram:
+		return Unixgram{}, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Сеть %s не поддерживается", network))
+}
+
+//Адрес из строки по названию сети, обратное net.Addr.String()
+func ResolveAddr(network, address string) (net.Addr, error) {
+	switch network {
+	case unixgram:
+		return &net.UnixAddr{Name: address, Net: unixgram}, nil
+	}
+	return net.ResolveUDPAddr(udp, address)
+}
@@ -0,0 +1,167 @@
+package transport_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+	"github.com/egovorukhin/egoudp/transport"
+)
+
+func TestUnixgram(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.sock")
+	u := transport.Unixgram{Dir: dir}
+
+	srv, err := u.Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt, err := u.Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = clt.Write([]byte("ping"))
+	buf := make([]byte, 16)
+	_ = srv.SetReadDeadline(time.Now().Add(time.Second))
+	n, addr, err := srv.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "ping" || addr.String() != clt.LocalAddr().String() {
+		t.Fatalf("ReadFrom: %q, %v, %v", buf[:n], addr, err)
+	}
+	_, _ = srv.WriteTo([]byte("pong"), addr)
+	_ = clt.SetReadDeadline(time.Now().Add(time.Second))
+	n, err = clt.Read(buf)
+	if err != nil || string(buf[:n]) != "pong" {
+		t.Fatalf("Read: %q, %v", buf[:n], err)
+	}
+
+	//Файлы сокетов удаляются при закрытии
+	_ = clt.Close()
+	_ = srv.Close()
+	for _, p := range []string{path, addr.String()} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+}
+
+//Файл от завершенного сервера заменяется, файл работающего сервера остается
+func TestUnixgramStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	u := transport.Unixgram{}
+
+	//Закрытие UnixConn не удаляет файл сокета
+	stale, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stale.Close()
+	srv, err := u.Listen(path)
+	if err != nil {
+		t.Fatalf("сокет прошлого запуска: %v", err)
+	}
+	defer srv.Close()
+
+	_, err = u.Listen(path)
+	if err == nil {
+		t.Fatal("сокет работающего сервера заменен")
+	}
+	clt, err := u.Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clt.Close()
+	_, _ = clt.Write([]byte("ping"))
+	buf := make([]byte, 16)
+	_ = srv.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := srv.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("ReadFrom: %q, %v", buf[:n], err)
+	}
+}
+
+func TestUnixgramClientServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	srv := server.New(server.Config{
+		Network:           transport.NetworkUnixgram,
+		Host:              path,
+		BufferSize:        1024,
+		DisconnectTimeout: 30,
+	})
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("echo", protocol.MethodGet, func(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+	})
+	disconnected := make(chan string, 1)
+	srv.OnDisconnected(func(c *server.Connection) {
+		disconnected <- c.IpAddress.String()
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	clt := client.New(client.Config{
+		Network:    transport.NetworkUnixgram,
+		Host:       path,
+		BufferSize: 1024,
+		Timeout:    3,
+	})
+	clt.SetLogger(ioutil.Discard, "", 0)
+	connected := make(chan bool, 1)
+	clt.OnConnected(func(c *client.Client) {
+		connected <- true
+	})
+	if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("клиент не подключился")
+	}
+
+	resp, err := clt.Send(protocol.NewRequest("echo", protocol.MethodGet).SetData("text/plain", protocol.ToRunes("привет")))
+	if err != nil || resp.Data.String() != "привет" {
+		t.Fatalf("echo: %v, %v", resp, err)
+	}
+
+	//Клиент определяется по имени его сокета
+	c := srv.GetConnections()["PC-1"]
+	addr, ok := c.IpAddress.(*net.UnixAddr)
+	if !ok || !strings.HasPrefix(addr.Name, "@egoudp-") {
+		t.Fatalf("IpAddress: %#v", c.IpAddress)
+	}
+
+	clt.Stop()
+	select {
+	case a := <-disconnected:
+		if a != addr.Name {
+			t.Errorf("отключен %s", a)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("клиент не отключился")
+	}
+}
+
+func TestResolveAddr(t *testing.T) {
+	addr, err := transport.ResolveAddr(transport.NetworkUnixgram, "/run/egoudp.sock")
+	if err != nil || addr.Network() != "unixgram" || addr.String() != "/run/egoudp.sock" {
+		t.Errorf("%v, %v", addr, err)
+	}
+	addr, err = transport.ResolveAddr("udp", "127.0.0.1:5655")
+	if err != nil || addr.String() != "127.0.0.1:5655" {
+		t.Errorf("%v, %v", addr, err)
+	}
+	if _, err := transport.New("tcp"); err == nil {
+		t.Error("New(tcp)")
+	}
+}
@@ -0,0 +1,42 @@
+package transport
+
+import "net"
+
+//Обертки сокетов транспорта: шифрование, захват, ограничение скорости.
+//PacketConn оборачивает сокет сервера, Conn - сокет клиента,
+//nil - сокет не оборачивается
+type Wrapper struct {
+	PacketConn func(c net.PacketConn) net.PacketConn
+	Conn       func(c net.Conn) net.Conn
+}
+
+type wrapped struct {
+	Transport
+	wrapper Wrapper
+}
+
+//Транспорт t, сокеты которого оборачиваются wrapper. Обертки можно
+//вкладывать друг в друга: при записи первой срабатывает внешняя,
+//при чтении - внутренняя
+func Wrap(t Transport, wrapper Wrapper) Transport {
+	return &wrapped{
+		Transport: Get(t),
+		wrapper:   wrapper,
+	}
+}
+
+func (w *wrapped) Listen(address string) (net.PacketConn, error) {
+	c, err := w.Transport.Listen(address)
+	if err != nil || w.wrapper.PacketConn == nil {
+		return c, err
+	}
+	return w.wrapper.PacketConn(c), nil
+}
+
+func (w *wrapped) Dial(address string) (net.Conn, error) {
+	c, err := w.Transport.Dial(address)
+	if err != nil || w.wrapper.Conn == nil {
+		return c, err
+	}
+	return w.wrapper.Conn(c), nil
+}
@@ -0,0 +1,170 @@
+package udptest
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+//Сокет в сети Network, ведет себя как *net.UDPConn:
+//запись не блокируется, чтение ждет пакета, закрытия или дедлайна
+type packetConn struct {
+	network *Network
+	addr    *net.UDPAddr
+	queue   chan *packet
+	closed  chan struct{}
+	once    sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+	//Закрывается при смене дедлайна, чтобы разбудить ожидающее чтение
+	changed chan struct{}
+}
+
+func newPacketConn(n *Network, addr *net.UDPAddr) *packetConn {
+	return &packetConn{
+		network: n,
+		addr:    addr,
+		queue:   make(chan *packet, QueueSize),
+		closed:  make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+}
+
+func (c *packetConn) push(p *packet) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+	select {
+	case c.queue <- p:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *packetConn) read() (*packet, error) {
+	for {
+		c.mu.Lock()
+		deadline, changed := c.deadline, c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-c.closed:
+			return nil, net.ErrClosed
+		default:
+		}
+
+		p, err := c.wait(deadline, changed)
+		if p != nil || err != nil {
+			return p, err
+		}
+	}
+}
+
+//Ждем пакет не дольше deadline, nil без ошибки - срок чтения изменился
+func (c *packetConn) wait(deadline time.Time, changed <-chan struct{}) (*packet, error) {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return nil, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case p := <-c.queue:
+		return p, nil
+	case <-c.closed:
+		return nil, net.ErrClosed
+	case <-timeout:
+		return nil, os.ErrDeadlineExceeded
+	case <-changed:
+		return nil, nil
+	}
+}
+
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	p, err := c.read()
+	if err != nil {
+		return 0, nil, c.opError("read", nil, err)
+	}
+	return copy(b, p.data), p.from, nil
+}
+
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, c.opError("write", addr, net.ErrClosed)
+	default:
+	}
+	c.network.send(c.addr, addr, b)
+	return len(b), nil
+}
+
+func (c *packetConn) Close() error {
+	err := c.opError("close", nil, net.ErrClosed)
+	c.once.Do(func() {
+		close(c.closed)
+		c.network.close(c)
+		err = nil
+	})
+	return err
+}
+
+func (c *packetConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *packetConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *packetConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	close(c.changed)
+	c.changed = make(chan struct{})
+	c.mu.Unlock()
+	return nil
+}
+
+//Запись не блокируется, дедлайн записи не нужен
+func (c *packetConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *packetConn) opError(op string, addr net.Addr, err error) error {
+	return &net.OpError{Op: op, Net: "udp", Source: c.addr, Addr: addr, Err: err}
+}
+
+//Подключенный сокет клиента, пакеты не от сервера отбрасываются
+type conn struct {
+	*packetConn
+	remote *net.UDPAddr
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	for {
+		p, err := c.read()
+		if err != nil {
+			return 0, c.opError("read", c.remote, err)
+		}
+		if p.from.String() == c.remote.String() {
+			return copy(b, p.data), nil
+		}
+	}
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.remote)
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
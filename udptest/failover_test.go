@@ -0,0 +1,141 @@
+package udptest_test
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/udptest"
+)
+
+//Клиент со списком серверов, в канал попадают адреса серверов,
+//к которым клиент подключился
+func connectEndpoints(t *testing.T, n *udptest.Network, failover client.Failover, endpoints ...client.Endpoint) (*client.Client, chan string) {
+	clt := client.New(client.Config{
+		BufferSize: 1024,
+		Timeout:    3,
+		Transport:  n,
+		Endpoints:  endpoints,
+		Failover:   failover,
+	}).(*client.Client)
+	clt.SetLogger(ioutil.Discard, "", 0)
+	attached := make(chan string, 16)
+	clt.OnAttached(func(c *client.Client, endpoint client.EndpointStatus) {
+		attached <- endpoint.Address
+	})
+	if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if clt.Started.Get() {
+			clt.Stop()
+		}
+	})
+	return clt, attached
+}
+
+func expectAttached(t *testing.T, attached chan string, address string, wait time.Duration) {
+	t.Helper()
+	select {
+	case a := <-attached:
+		if a != address {
+			t.Fatalf("подключен к %s, ожидался %s", a, address)
+		}
+	case <-time.After(wait):
+		t.Fatalf("нет подключения к %s за %v", address, wait)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	primary := startPort(t, n, 5655, 30)
+	startPort(t, n, 5656, 30)
+	clt, attached := connectEndpoints(t, n, client.Failover{Failures: 2},
+		client.Endpoint{Host: "backup", Port: 5656, Priority: 1},
+		client.Endpoint{Host: "main", Port: 5655},
+	)
+	expectAttached(t, attached, "main:5655", 3*time.Second)
+	if s, err := echo(clt, "main"); err != nil || s != "main" {
+		t.Fatalf("echo: %q, %v", s, err)
+	}
+
+	_ = primary.Stop()
+	expectAttached(t, attached, "backup:5656", 6*time.Second)
+	if s, err := echo(clt, "backup"); err != nil || s != "backup" {
+		t.Fatalf("echo: %q, %v", s, err)
+	}
+	health := clt.Health()
+	if len(health) != 2 || health[0].Healthy || health[0].Failures == 0 || !health[1].Current || !health[1].Healthy {
+		t.Errorf("health: %v", health)
+	}
+	if e, ok := clt.Endpoint(); !ok || e.Address != "backup:5656" || !clt.Connected.Get() {
+		t.Errorf("endpoint: %s", e)
+	}
+}
+
+//Основной сервер недоступен при запуске, клиент работает с резервным
+//и возвращается на основной, когда тот начинает отвечать
+func TestFailBack(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	backup := startPort(t, n, 5656, 30)
+	clt, attached := connectEndpoints(t, n, client.Failover{Failures: 2, FailBack: true, FailBackInterval: 1},
+		client.Endpoint{Host: "main", Port: 5655},
+		client.Endpoint{Host: "backup", Port: 5656, Priority: 1},
+	)
+	expectAttached(t, attached, "backup:5656", 6*time.Second)
+	expect(t, backup.connected, "PC-1", time.Second)
+
+	primary := startPort(t, n, 5655, 30)
+	expectAttached(t, attached, "main:5655", 6*time.Second)
+	expect(t, primary.connected, "PC-1", time.Second)
+	//Резервный сервер получает событие отключения
+	expect(t, backup.disconnected, "PC-1", time.Second)
+	if s, err := echo(clt, "main"); err != nil || s != "main" {
+		t.Fatalf("echo: %q, %v", s, err)
+	}
+}
+
+//Проверка молчащего основного сервера не задерживает проверку текущего:
+//отказ резервного сервера обнаруживается за Failures интервалов
+func TestFailBackProbe(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	backup := startPort(t, n, 5656, 30)
+	clt, attached := connectEndpoints(t, n, client.Failover{Failures: 2, FailBack: true, FailBackInterval: 1},
+		client.Endpoint{Host: "main", Port: 5655},
+		client.Endpoint{Host: "backup", Port: 5656, Priority: 1},
+	)
+	expectAttached(t, attached, "backup:5656", 6*time.Second)
+
+	_ = backup.Stop()
+	start := time.Now()
+	for {
+		if health := clt.Health(); !health[1].Healthy {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("отказ резервного сервера не обнаружен")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+//Без FailBack клиент остается на резервном сервере
+func TestNoFailBack(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	startPort(t, n, 5656, 30)
+	clt, attached := connectEndpoints(t, n, client.Failover{Failures: 2},
+		client.Endpoint{Host: "main", Port: 5655},
+		client.Endpoint{Host: "backup", Port: 5656, Priority: 1},
+	)
+	expectAttached(t, attached, "backup:5656", 6*time.Second)
+	startPort(t, n, 5655, 30)
+	select {
+	case a := <-attached:
+		t.Fatalf("переключение на %s", a)
+	case <-time.After(3 * time.Second):
+	}
+	if e, _ := clt.Endpoint(); e.Address != "backup:5656" {
+		t.Errorf("endpoint: %s", e)
+	}
+}
@@ -0,0 +1,212 @@
+package udptest_test
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/egovorukhin/egoudp/client"
+	"github.com/egovorukhin/egoudp/protocol"
+	"github.com/egovorukhin/egoudp/server"
+	"github.com/egovorukhin/egoudp/udptest"
+)
+
+const port = 5655
+
+//Сервер с маршрутом echo и каналами событий подключения/отключения
+type testServer struct {
+	*server.Server
+	connected    chan string
+	disconnected chan string
+}
+
+func start(t *testing.T, n *udptest.Network, disconnectTimeout int) *testServer {
+	return startPort(t, n, port, disconnectTimeout)
+}
+
+func startPort(t *testing.T, n *udptest.Network, port, disconnectTimeout int) *testServer {
+	srv := &testServer{
+		Server: server.New(server.Config{
+			Port:              port,
+			BufferSize:        1024,
+			DisconnectTimeout: disconnectTimeout,
+			Transport:         n,
+		}).(*server.Server),
+		connected:    make(chan string, 16),
+		disconnected: make(chan string, 16),
+	}
+	srv.SetLogger(ioutil.Discard, "", 0)
+	srv.SetRoute("echo", protocol.MethodGet, func(c *server.Connection, resp protocol.IResponse, req protocol.Request) {
+		c.Send1(resp.SetData(protocol.StatusCodeOK, req.Data))
+	})
+	srv.OnConnected(func(c *server.Connection) {
+		srv.connected <- c.Hostname
+	})
+	srv.OnDisconnected(func(c *server.Connection) {
+		srv.disconnected <- c.Hostname
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if srv.Started.Get() {
+			_ = srv.Stop()
+		}
+	})
+	return srv
+}
+
+//Запускаем клиента и ждем подключения
+func connect(t *testing.T, n *udptest.Network, wait time.Duration) *client.Client {
+	clt := client.New(client.Config{
+		Host:       "server",
+		Port:       port,
+		BufferSize: 1024,
+		Timeout:    3,
+		Transport:  n,
+	}).(*client.Client)
+	clt.SetLogger(ioutil.Discard, "", 0)
+	connected := make(chan bool, 16)
+	clt.OnConnected(func(c *client.Client) {
+		connected <- true
+	})
+	if err := clt.Start("pc-1", "user", "HQ", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if clt.Started.Get() {
+			clt.Stop()
+		}
+	})
+	select {
+	case <-connected:
+	case <-time.After(wait):
+		t.Fatalf("клиент не подключился за %v, сеть: %+v", wait, n.Stats())
+	}
+	return clt
+}
+
+func expect(t *testing.T, ch chan string, hostname string, wait time.Duration) {
+	t.Helper()
+	select {
+	case h := <-ch:
+		if h != hostname {
+			t.Fatalf("hostname: %s", h)
+		}
+	case <-time.After(wait):
+		t.Fatalf("нет события за %v", wait)
+	}
+}
+
+func echo(clt *client.Client, data string) (string, error) {
+	resp, err := clt.Send(protocol.NewRequest("echo", protocol.MethodGet).SetData("text/plain", protocol.ToRunes(data)))
+	if err != nil {
+		return "", err
+	}
+	return resp.Data.String(), nil
+}
+
+func TestConnect(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	srv := start(t, n, 30)
+	connect(t, n, time.Second)
+	expect(t, srv.connected, "PC-1", time.Second)
+
+	c, ok := srv.GetConnections()["PC-1"]
+	if !ok {
+		t.Fatal("подключение отсутствует")
+	}
+	if c.IpAddress.String() != "10.0.0.2:49153" || c.Login != "user" {
+		t.Errorf("%s", c)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	start(t, n, 30)
+	clt := connect(t, n, time.Second)
+	data, err := echo(clt, "привет")
+	if err != nil || data != "привет" {
+		t.Fatalf("echo: %q, %v", data, err)
+	}
+}
+
+//Дубли и перестановки ответов не ломают сопоставление запросов
+func TestRequestDuplicateReorder(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{
+		Duplicate: 0.5,
+		Reorder:   0.3,
+		Latency:   5 * time.Millisecond,
+		Jitter:    5 * time.Millisecond,
+		Seed:      1,
+	})
+	start(t, n, 30)
+	clt := connect(t, n, 2*time.Second)
+	for _, s := range []string{"a", "b"} {
+		data, err := echo(clt, s)
+		if err != nil || data != s {
+			t.Fatalf("echo %s: %q, %v", s, data, err)
+		}
+	}
+	if s := n.Stats(); s.Duplicated == 0 || s.Reordered == 0 {
+		t.Errorf("%+v", s)
+	}
+}
+
+//Клиент повторяет подключение каждую секунду, пока не получит ответ
+func TestConnectLoss(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{Loss: 0.5, Seed: 9})
+	start(t, n, 30)
+	connect(t, n, 5*time.Second)
+	if n.Stats().Lost == 0 {
+		t.Errorf("%+v", n.Stats())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	start(t, n, 30)
+	clt := connect(t, n, time.Second)
+
+	n.SetConfig(udptest.Config{Loss: 1})
+	_, err := echo(clt, "a")
+	if err == nil {
+		t.Fatal("ответ без сети")
+	}
+}
+
+//Запрос больше MTU теряется, как и в настоящей сети
+func TestMTU(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{MTU: 200})
+	start(t, n, 30)
+	clt := connect(t, n, time.Second)
+	if _, err := echo(clt, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := echo(clt, string(make([]byte, 300))); err == nil {
+		t.Fatal("ответ на пакет больше MTU")
+	}
+	if n.Stats().Oversized == 0 {
+		t.Errorf("%+v", n.Stats())
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	srv := start(t, n, 30)
+	clt := connect(t, n, time.Second)
+	clt.Stop()
+	expect(t, srv.disconnected, "PC-1", 2*time.Second)
+	if _, ok := srv.GetConnections()["PC-1"]; ok {
+		t.Error("подключение не удалено")
+	}
+}
+
+//Связь оборвалась - сервер отключает клиента по DisconnectTimeout
+func TestDisconnectTimeout(t *testing.T) {
+	n := udptest.NewNetwork(udptest.Config{})
+	srv := start(t, n, 1)
+	connect(t, n, time.Second)
+	n.SetConfig(udptest.Config{Loss: 1})
+	expect(t, srv.disconnected, "PC-1", 3*time.Second)
+}
@@ -0,0 +1,261 @@
+package udptest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Условия сети. Вероятности задаются от 0 до 1
+type Config struct {
+	//Потеря пакета
+	Loss float64
+	//Дублирование пакета
+	Duplicate float64
+	//Перестановка: пакет задерживается и доставляется после
+	//следующего пакета тому же получателю
+	Reorder float64
+	//Задержка доставки и случайная добавка к ней от 0 до Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	//Пакеты больше MTU байт отбрасываются, 0 - без ограничения
+	MTU int
+	//Начальное значение генератора, при одинаковом Seed и порядке
+	//отправки решения о потере, дублировании и перестановке совпадают
+	Seed int64
+}
+
+//Счетчики сети
+type Stats struct {
+	Sent       uint64
+	Delivered  uint64
+	Lost       uint64
+	Duplicated uint64
+	Reordered  uint64
+	Oversized  uint64
+}
+
+//Размер очереди приема сокета, при переполнении пакеты отбрасываются
+const QueueSize = 1024
+
+//Сколько пакет ждет обгоняющего его пакета при перестановке
+const reorderTimeout = 50 * time.Millisecond
+
+//Сеть в памяти процесса. Реализует transport.Transport, поэтому
+//сервер и клиент подключаются к ней через Config.Transport.
+//Сокеты сервера получают адрес 10.0.0.1:port, сокеты клиентов -
+//10.0.0.2, 10.0.0.3 и т.д. Хост при подключении не учитывается,
+//пакет доставляется сокету, открытому на указанном порту
+type Network struct {
+	sync.Mutex
+	config Config
+	rand   *rand.Rand
+	conns  map[string]*packetConn
+	held   map[string]*packet
+	hosts  int
+	port   int
+	stats  Stats
+}
+
+type packet struct {
+	from *net.UDPAddr
+	to   string
+	data []byte
+}
+
+func NewNetwork(config Config) *Network {
+	return &Network{
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+		conns:  map[string]*packetConn{},
+		held:   map[string]*packet{},
+		hosts:  1,
+		port:   49152,
+	}
+}
+
+//Меняем условия сети на ходу, например, чтобы оборвать связь.
+//Генератор случайных чисел не пересоздается
+func (n *Network) SetConfig(config Config) {
+	n.Lock()
+	n.config = config
+	n.Unlock()
+}
+
+func (n *Network) Config() Config {
+	n.Lock()
+	defer n.Unlock()
+	return n.config
+}
+
+func (n *Network) Stats() Stats {
+	n.Lock()
+	defer n.Unlock()
+	return n.stats
+}
+
+//Открываем сокет сервера на порту из address
+func (n *Network) Listen(address string) (net.PacketConn, error) {
+	port, err := parsePort(address)
+	if err != nil {
+		return nil, err
+	}
+	n.Lock()
+	defer n.Unlock()
+	if port == 0 {
+		port = n.nextPort()
+	}
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: port}
+	if _, ok := n.conns[addr.String()]; ok {
+		return nil, errors.New(fmt.Sprintf("Адрес %s уже используется", addr))
+	}
+	return n.open(addr), nil
+}
+
+//Открываем сокет клиента, подключенный к порту из address
+func (n *Network) Dial(address string) (net.Conn, error) {
+	port, err := parsePort(address)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		return nil, errors.New(fmt.Sprintf("Не указан порт в адресе %s", address))
+	}
+	n.Lock()
+	defer n.Unlock()
+	n.hosts++
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, byte(n.hosts>>8), byte(n.hosts)), Port: n.nextPort()}
+	return &conn{
+		packetConn: n.open(addr),
+		remote:     &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: port},
+	}, nil
+}
+
+func parsePort(address string) (int, error) {
+	_, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, errors.New(fmt.Sprintf("Неверный порт в адресе %s", address))
+	}
+	return port, nil
+}
+
+func (n *Network) nextPort() int {
+	n.port++
+	return n.port
+}
+
+func (n *Network) open(addr *net.UDPAddr) *packetConn {
+	c := newPacketConn(n, addr)
+	n.conns[addr.String()] = c
+	return c
+}
+
+func (n *Network) close(c *packetConn) {
+	n.Lock()
+	if n.conns[c.addr.String()] == c {
+		delete(n.conns, c.addr.String())
+	}
+	n.Unlock()
+}
+
+//Отправка пакета: решаем его судьбу по условиям сети
+//и доставляем сразу, либо с задержкой
+func (n *Network) send(from *net.UDPAddr, to net.Addr, b []byte) {
+	n.Lock()
+	defer n.Unlock()
+	n.stats.Sent++
+	if n.config.MTU > 0 && len(b) > n.config.MTU {
+		n.stats.Oversized++
+		return
+	}
+	if n.roll(n.config.Loss) {
+		n.stats.Lost++
+		return
+	}
+	copies := 1
+	if n.roll(n.config.Duplicate) {
+		n.stats.Duplicated++
+		copies++
+	}
+	for i := 0; i < copies; i++ {
+		p := &packet{
+			from: from,
+			to:   to.String(),
+			data: append([]byte(nil), b...),
+		}
+		if n.roll(n.config.Reorder) {
+			n.stats.Reordered++
+			n.hold(p)
+			continue
+		}
+		n.schedule(p, n.delay())
+	}
+}
+
+func (n *Network) roll(p float64) bool {
+	return p > 0 && n.rand.Float64() < p
+}
+
+func (n *Network) delay() time.Duration {
+	d := n.config.Latency
+	if n.config.Jitter > 0 {
+		d += time.Duration(n.rand.Int63n(int64(n.config.Jitter)))
+	}
+	return d
+}
+
+//Без задержки доставляем под блокировкой, так порядок
+//доставки совпадает с порядком отправки
+func (n *Network) schedule(p *packet, d time.Duration) {
+	if d <= 0 {
+		n.deliver(p)
+		return
+	}
+	time.AfterFunc(d, func() {
+		n.Lock()
+		n.deliver(p)
+		n.Unlock()
+	})
+}
+
+//Придерживаем пакет до следующего пакета тому же получателю,
+//но не дольше reorderTimeout
+func (n *Network) hold(p *packet) {
+	if prev, ok := n.held[p.to]; ok {
+		delete(n.held, p.to)
+		n.deliver(prev)
+	}
+	n.held[p.to] = p
+	time.AfterFunc(n.config.Latency+reorderTimeout, func() {
+		n.Lock()
+		n.release(p)
+		n.Unlock()
+	})
+}
+
+func (n *Network) release(p *packet) {
+	if n.held[p.to] == p {
+		delete(n.held, p.to)
+		n.deliver(p)
+	}
+}
+
+func (n *Network) deliver(p *packet) {
+	c, ok := n.conns[p.to]
+	if !ok || !c.push(p) {
+		n.stats.Lost++
+	} else {
+		n.stats.Delivered++
+	}
+	if held, ok := n.held[p.to]; ok && held != p {
+		n.release(held)
+	}
+}
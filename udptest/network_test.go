@@ -0,0 +1,154 @@
+package udptest
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func pair(t *testing.T, n *Network) (net.PacketConn, net.Conn) {
+	srv, err := n.Listen(":5655")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt, err := n.Dial("server:5655")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = clt.Close()
+		_ = srv.Close()
+	})
+	return srv, clt
+}
+
+func receive(t *testing.T, conn net.PacketConn, count int) []string {
+	var list []string
+	buf := make([]byte, 1024)
+	for i := 0; i < count; i++ {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("получено %v: %v", list, err)
+		}
+		list = append(list, string(buf[:n]))
+	}
+	return list
+}
+
+func TestDeliver(t *testing.T) {
+	n := NewNetwork(Config{})
+	srv, clt := pair(t, n)
+	_, _ = clt.Write([]byte("ping"))
+
+	buf := make([]byte, 1024)
+	size, addr, err := srv.ReadFrom(buf)
+	if err != nil || string(buf[:size]) != "ping" || addr.String() != clt.LocalAddr().String() {
+		t.Fatalf("ReadFrom: %q, %v, %v", buf[:size], addr, err)
+	}
+	_, _ = srv.WriteTo([]byte("pong"), addr)
+	size, err = clt.Read(buf)
+	if err != nil || string(buf[:size]) != "pong" {
+		t.Fatalf("Read: %q, %v", buf[:size], err)
+	}
+	if _, err := n.Listen(":5655"); err == nil {
+		t.Error("Listen на занятом порту")
+	}
+}
+
+//Одинаковый Seed - одинаковые потери
+func TestLossSeed(t *testing.T) {
+	lost := func() []string {
+		n := NewNetwork(Config{Loss: 0.5, Seed: 7})
+		srv, clt := pair(t, n)
+		for _, s := range []string{"1", "2", "3", "4", "5", "6", "7", "8"} {
+			_, _ = clt.Write([]byte(s))
+		}
+		return receive(t, srv, int(n.Stats().Delivered))
+	}
+	a, b := lost(), lost()
+	if len(a) == 0 || len(a) == 8 || len(a) != len(b) {
+		t.Fatalf("%v, %v", a, b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("%v, %v", a, b)
+		}
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	n := NewNetwork(Config{Duplicate: 1})
+	srv, clt := pair(t, n)
+	_, _ = clt.Write([]byte("a"))
+	if got := receive(t, srv, 2); got[0] != "a" || got[1] != "a" {
+		t.Errorf("%v", got)
+	}
+}
+
+//Придержанный пакет доставляется после следующего,
+//а без следующего - по таймауту
+func TestReorder(t *testing.T) {
+	n := NewNetwork(Config{Reorder: 1})
+	srv, clt := pair(t, n)
+	_, _ = clt.Write([]byte("a"))
+	n.SetConfig(Config{})
+	_, _ = clt.Write([]byte("b"))
+	if got := receive(t, srv, 2); got[0] != "b" || got[1] != "a" {
+		t.Errorf("%v", got)
+	}
+
+	n.SetConfig(Config{Reorder: 1})
+	_, _ = clt.Write([]byte("c"))
+	if got := receive(t, srv, 1); got[0] != "c" {
+		t.Errorf("%v", got)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	n := NewNetwork(Config{Latency: 30 * time.Millisecond})
+	srv, clt := pair(t, n)
+	start := time.Now()
+	_, _ = clt.Write([]byte("a"))
+	receive(t, srv, 1)
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("доставлен через %v", d)
+	}
+}
+
+func TestMTU(t *testing.T) {
+	n := NewNetwork(Config{MTU: 4})
+	srv, clt := pair(t, n)
+	_, _ = clt.Write([]byte("large"))
+	_, _ = clt.Write([]byte("ok"))
+	if got := receive(t, srv, 1); got[0] != "ok" {
+		t.Errorf("%v", got)
+	}
+	if s := n.Stats(); s.Oversized != 1 || s.Delivered != 1 {
+		t.Errorf("%+v", s)
+	}
+}
+
+func TestDeadlineClose(t *testing.T) {
+	n := NewNetwork(Config{})
+	srv, _ := pair(t, n)
+	buf := make([]byte, 16)
+
+	_ = srv.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, _, err := srv.ReadFrom(buf)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+
+	_ = srv.SetReadDeadline(time.Time{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = srv.Close()
+	}()
+	_, _, err = srv.ReadFrom(buf)
+	if !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+}